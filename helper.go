@@ -20,6 +20,9 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// TimeLayout create_time、update_time 等时间字段使用的格式
+const TimeLayout = "2006-01-02 15:04:05"
+
 func Struct2BsonD(doc interface{}) (bson.D, error) {
 	// 将结构体编码为BSON字节序列
 	data, err := bson.Marshal(doc)
@@ -98,10 +101,23 @@ func MapToBsonD(m map[string]interface{}) (bson.D, error) {
 
 // ParseSortString 将形如 "field1=1,field2=-1" 的字符串转换为 bson.M 映射
 func ParseSortString(sortString string) (bson.M, error) {
+	sortD, err := ParseSortStringD(sortString)
+	if err != nil {
+		return nil, err
+	}
+	sortClause := bson.M{}
+	for _, e := range sortD {
+		sortClause[e.Key] = e.Value
+	}
+	return sortClause, nil
+}
+
+// ParseSortStringD 同 ParseSortString，返回保留字段顺序的 bson.D，用于多字段排序和索引
+func ParseSortStringD(sortString string) (bson.D, error) {
 	if len(strings.TrimSpace(sortString)) == 0 {
 		return nil, fmt.Errorf("is empty string")
 	}
-	sortClause := bson.M{}
+	sortClause := bson.D{}
 
 	// 分割字符串为字段和顺序对
 	parts := strings.Split(sortString, ",")
@@ -110,7 +126,7 @@ func ParseSortString(sortString string) (bson.M, error) {
 		// 分离字段名和顺序
 		if _, err := strconv.Unquote("\"" + part); err == nil {
 			// 如果是 JSON 字符串格式，直接添加到 sortClause
-			sortClause[part] = 1
+			sortClause = append(sortClause, bson.E{Key: part, Value: 1})
 			continue
 		}
 
@@ -135,7 +151,7 @@ func ParseSortString(sortString string) (bson.M, error) {
 		}
 
 		// 将字段名和顺序添加到映射
-		sortClause[fieldName] = order
+		sortClause = append(sortClause, bson.E{Key: fieldName, Value: order})
 	}
 
 	return sortClause, nil
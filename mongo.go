@@ -8,7 +8,6 @@ import (
 	"sync"
 	"time"
 
-	dhlog "github.com/lepingbeta/go-common-v2-dh-log"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -48,7 +47,7 @@ func (db *Database) Connect(uri string, ts time.Duration) error {
 	databaseName = strings.TrimPrefix(u.Path, "/")
 	fmt.Println("数据库名:", databaseName)
 
-	clientOptions := options.Client().ApplyURI(uri)
+	clientOptions := options.Client().ApplyURI(uri).SetPoolMonitor(PoolMonitor())
 	client, err := mongo.Connect(context.Background(), clientOptions)
 	if err != nil {
 		return err
@@ -66,21 +65,54 @@ func GetDatabase() *mongo.Database {
 	return GetInstance().GetClient().Database(databaseName)
 }
 
+// DatabaseName 返回当前使用的数据库名
+func DatabaseName() string {
+	return databaseName
+}
+
+// SetDatabaseName 切换包级函数使用的数据库，Connect 时取自连接字符串的路径
+func SetDatabaseName(name string) {
+	databaseName = name
+}
+
+type databaseKey struct{}
+
+// WithDatabase 在 ctx 中指定本次调用使用的数据库，覆盖 DatabaseName
+func WithDatabase(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, databaseKey{}, name)
+}
+
+// DatabaseFromContext 返回 WithDatabase 指定的数据库名，未指定时返回空字符串
+func DatabaseFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(databaseKey{}).(string)
+	return name
+}
+
+// GetDatabaseWithContext 返回 ctx 中指定的数据库，未指定时同 GetDatabase
+func GetDatabaseWithContext(ctx context.Context) *mongo.Database {
+	if name := DatabaseFromContext(ctx); name != "" {
+		return GetInstance().GetClient().Database(name)
+	}
+	return GetDatabase()
+}
+
 func Count(collectionName string, filter interface{}, opts ...*options.CountOptions) (int64, error) {
-	collection := GetDatabase().Collection(collectionName)
-	ctx, cancel := context.WithTimeout(context.Background(), timeoutSec)
-	defer cancel()
+	return CountWithContext(context.Background(), collectionName, filter, opts...)
+}
+
+// CountWithContext 同 Count，ctx 用于取消和传递追踪信息
+func CountWithContext(ctx context.Context, collectionName string, filter interface{}, opts ...*options.CountOptions) (int64, error) {
+	op := &Operation{Kind: KindCount, Collection: collectionName, Filter: filter, Options: toInterfaces(opts)}
+	result, err := execute(ctx, op)
+	return resultAs[int64](op, result, err)
+}
 
-	// 构建查询条件
-	count, err := collection.CountDocuments(ctx, filter, opts...)
+func countDocuments(ctx context.Context, collection *mongo.Collection, op *Operation) (int64, error) {
+	opts, err := convertOpts[*options.CountOptions](op.Kind, op.Options)
 	if err != nil {
-		dhlog.Info(err.Error())
-		return count, err
+		return 0, err
 	}
-
-	// 处理查询结果
-	dhlog.Info("查询数量：", count)
-	return count, err
+	return collection.CountDocuments(ctx, op.Filter, opts...)
 }
 
 // Disconnect 断开与 MongoDB 数据库的连接
@@ -0,0 +1,305 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultAuditCollection 审计记录默认写入的集合
+const DefaultAuditCollection = "audit_log"
+
+// AuditEntry 一条审计记录，对应一个被修改的文档
+type AuditEntry struct {
+	Actor      string        `bson:"actor"`
+	Collection string        `bson:"collection"`
+	DocumentID interface{}   `bson:"document_id"`
+	Operation  string        `bson:"operation"`
+	Changes    []AuditChange `bson:"changes"`
+	Time       string        `bson:"time"`
+}
+
+// AuditChange 一处字段变化，见 DiffEntry
+type AuditChange struct {
+	Path string      `bson:"path"`
+	Kind DiffKind    `bson:"kind"`
+	Old  interface{} `bson:"old,omitempty"`
+	New  interface{} `bson:"new,omitempty"`
+}
+
+// AuditOptions 审计配置
+type AuditOptions struct {
+	// Collection 审计记录写入的集合，为空时使用 DefaultAuditCollection
+	Collection string
+	// Include 需要审计的集合，为空时审计全部集合
+	Include []string
+	// Exclude 不审计的集合，优先于 Include
+	Exclude []string
+	// RedactRules 写入前对变化的值脱敏
+	RedactRules []RedactRule
+	// FailOnError 为 true 时写审计记录失败会作为操作的错误返回（数据已修改），否则只记录日志
+	FailOnError bool
+}
+
+type actorKey struct{}
+
+// WithActor 在 ctx 中设置操作人，审计记录的 actor 取自该值
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext 返回 WithActor 设置的操作人
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+var (
+	auditMu      sync.RWMutex
+	auditEnabled bool
+	auditOptions AuditOptions
+)
+
+// EnableAudit 开启审计：InsertOne、Update、Delete 和 FindOneAndUpdate 成功后，
+// 为每个被修改的文档写入一条包含操作人、文档 _id 和前后差异的记录
+func EnableAudit(opts AuditOptions) {
+	if opts.Collection == "" {
+		opts.Collection = DefaultAuditCollection
+	}
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	auditEnabled = true
+	auditOptions = opts
+}
+
+// DisableAudit 关闭审计
+func DisableAudit() {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	auditEnabled = false
+}
+
+// auditConfig 返回集合是否需要审计及当前配置
+func auditConfig(collectionName string) (AuditOptions, bool) {
+	auditMu.RLock()
+	defer auditMu.RUnlock()
+	o := auditOptions
+	if !auditEnabled || collectionName == o.Collection {
+		return o, false
+	}
+	for _, c := range o.Exclude {
+		if c == collectionName {
+			return o, false
+		}
+	}
+	if len(o.Include) == 0 {
+		return o, true
+	}
+	for _, c := range o.Include {
+		if c == collectionName {
+			return o, true
+		}
+	}
+	return o, false
+}
+
+func auditMiddleware(next Handler) Handler {
+	return func(ctx context.Context, op *Operation) (interface{}, error) {
+		if !op.Kind.IsWrite() {
+			return next(ctx, op)
+		}
+		o, ok := auditConfig(op.Collection)
+		if !ok {
+			return next(ctx, op)
+		}
+
+		// 修改前的文档
+		var before []bson.M
+		if op.Kind != KindInsertOne {
+			var err error
+			if before, err = targetImages(ctx, next, op); err != nil {
+				return nil, err
+			}
+		}
+
+		result, err := next(ctx, op)
+		if err != nil {
+			return result, err
+		}
+
+		entries, aerr := buildAuditEntries(ctx, next, op, result, before, o)
+		if aerr == nil && len(entries) > 0 {
+			for _, e := range entries {
+				if _, aerr = next(ctx, &Operation{Kind: KindInsertOne, Collection: o.Collection, Document: e}); aerr != nil {
+					break
+				}
+			}
+		}
+		if aerr != nil {
+			logf(LevelError, "写入审计记录失败："+aerr.Error(), Field{Key: "collection", Value: op.Collection})
+			if o.FailOnError {
+				return result, aerr
+			}
+		}
+		return result, nil
+	}
+}
+
+// targetImages 通过 next 读取操作将要修改的文档。单文档操作按操作的排序选出目标，
+// 并把过滤条件限定到该 _id，保证修改前的文档就是实际被修改的文档
+func targetImages(ctx context.Context, next Handler, op *Operation) ([]bson.M, error) {
+	if op.Kind == KindUpdateMany || op.Kind == KindDeleteMany {
+		return loadImages(ctx, next, op.Collection, op.Filter)
+	}
+	filter := op.Filter
+	if filter == nil {
+		filter = bson.M{}
+	}
+	findOpts := options.FindOne()
+	for _, o := range op.Options {
+		if fo, ok := o.(*options.FindOneAndUpdateOptions); ok && fo != nil && fo.Sort != nil {
+			findOpts.SetSort(fo.Sort)
+		}
+	}
+	doc, err := next(ctx, &Operation{Kind: KindFindOne, Collection: op.Collection, Filter: filter, Options: []interface{}{findOpts}})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	d := doc.(bson.M)
+	op.Filter = andFilter(op.Filter, bson.M{"_id": d["_id"]})
+	return []bson.M{d}, nil
+}
+
+// loadImages 通过 next 读取满足条件的全部文档
+func loadImages(ctx context.Context, next Handler, collectionName string, filter interface{}) ([]bson.M, error) {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	docs, err := next(ctx, &Operation{Kind: KindFindList, Collection: collectionName, Filter: filter})
+	if err != nil {
+		return nil, err
+	}
+	list, _ := docs.([]bson.M)
+	return list, nil
+}
+
+// imagesByID 按 _id 读取修改后的文档
+func imagesByID(ctx context.Context, next Handler, collectionName string, ids bson.A) (map[interface{}]bson.M, error) {
+	out := map[interface{}]bson.M{}
+	if len(ids) == 0 {
+		return out, nil
+	}
+	docs, err := loadImages(ctx, next, collectionName, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range docs {
+		out[idKey(d["_id"])] = d
+	}
+	return out, nil
+}
+
+// idKey 将 _id 转为可作为 map 键的值
+func idKey(id interface{}) interface{} {
+	switch id.(type) {
+	case bson.M, bson.D, bson.A:
+		return diffValueString(id)
+	}
+	if n, ok := id.(int32); ok {
+		return int64(n)
+	}
+	return id
+}
+
+func buildAuditEntries(ctx context.Context, next Handler, op *Operation, result interface{}, before []bson.M, o AuditOptions) ([]AuditEntry, error) {
+	now := time.Now().Format(TimeLayout)
+	actor := ActorFromContext(ctx)
+	newEntry := func(id interface{}, a, b interface{}) (*AuditEntry, error) {
+		diff, err := DiffBson(a, b)
+		if err != nil || diff.Empty() {
+			return nil, err
+		}
+		changes := make([]AuditChange, 0, len(diff))
+		for _, d := range diff {
+			changes = append(changes, AuditChange{
+				Path: d.Path,
+				Kind: d.Kind,
+				Old:  redactChange(d.Path, d.Old, o.RedactRules),
+				New:  redactChange(d.Path, d.New, o.RedactRules),
+			})
+		}
+		return &AuditEntry{Actor: actor, Collection: op.Collection, DocumentID: id, Operation: string(op.Kind), Changes: changes, Time: now}, nil
+	}
+
+	var afterIDs bson.A
+	switch op.Kind {
+	case KindInsertOne:
+		res, _ := result.(*mongo.InsertOneResult)
+		if res == nil {
+			return nil, nil
+		}
+		afterIDs = bson.A{res.InsertedID}
+	case KindDeleteOne, KindDeleteMany:
+	default:
+		for _, d := range before {
+			afterIDs = append(afterIDs, d["_id"])
+		}
+		if res, ok := result.(*mongo.UpdateResult); ok && res.UpsertedID != nil {
+			afterIDs = append(afterIDs, res.UpsertedID)
+		}
+		if doc, ok := result.(bson.M); ok && len(before) == 0 && doc != nil {
+			// FindOneAndUpdate upsert 且返回新文档
+			afterIDs = append(afterIDs, doc["_id"])
+		}
+	}
+	after, err := imagesByID(ctx, next, op.Collection, afterIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []AuditEntry
+	for _, b := range before {
+		id := b["_id"]
+		a := after[idKey(id)]
+		delete(after, idKey(id))
+		e, err := newEntry(id, b, a)
+		if err != nil {
+			return nil, err
+		}
+		if e != nil {
+			entries = append(entries, *e)
+		}
+	}
+	// 新插入的文档
+	for _, id := range afterIDs {
+		a, ok := after[idKey(id)]
+		if !ok {
+			continue
+		}
+		delete(after, idKey(id))
+		e, err := newEntry(id, nil, a)
+		if err != nil {
+			return nil, err
+		}
+		if e != nil {
+			entries = append(entries, *e)
+		}
+	}
+	return entries, nil
+}
+
+// redactChange 缺少的一侧保持为 nil，不替换为占位值
+func redactChange(path string, v interface{}, rules []RedactRule) interface{} {
+	if v == nil {
+		return nil
+	}
+	return redactValue(path, v, rules)
+}
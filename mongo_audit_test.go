@@ -0,0 +1,74 @@
+package mongodb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestAudit(t *testing.T) {
+	m := useTestMemoryBackend(t)
+	EnableAudit(AuditOptions{Include: []string{"user"}, RedactRules: []RedactRule{RedactKeys("password")}})
+	defer DisableAudit()
+
+	ctx := WithActor(context.Background(), "admin")
+	_, err := InsertOneWithContext(ctx, "user", bson.D{{Key: "_id", Value: 1}, {Key: "name", Value: "Alice"}, {Key: "password", Value: "secret"}})
+	assert.NoError(t, err)
+	_, err = UpdateWithContext(ctx, "user", string(KindUpdateOne), bson.M{"_id": 1}, bson.M{"name": "Bob", "password": "secret2"})
+	assert.NoError(t, err)
+	// 没有变化时不记录
+	_, err = UpdateWithContext(ctx, "user", string(KindUpdateOne), bson.M{"_id": 1}, bson.M{"name": "Bob"})
+	assert.NoError(t, err)
+	_, err = DeleteWithContext(context.Background(), "user", string(KindDeleteOne), bson.M{"_id": 1})
+	assert.NoError(t, err)
+	// 未包含的集合不审计
+	_, err = InsertOneWithContext(ctx, "order", bson.M{"_id": 1})
+	assert.NoError(t, err)
+
+	entries := m.Documents(DefaultAuditCollection)
+	assert.Len(t, entries, 3)
+	for _, e := range entries {
+		assert.Equal(t, "user", e["collection"])
+		assert.Equal(t, int32(1), e["document_id"])
+		assert.NotEmpty(t, e["time"])
+	}
+
+	assert.Equal(t, "admin", entries[0]["actor"])
+	assert.Equal(t, "InsertOne", entries[0]["operation"])
+	assert.Contains(t, entries[0]["changes"], bson.M{"path": "password", "kind": "added", "new": RedactedValue})
+
+	assert.Equal(t, "UpdateOne", entries[1]["operation"])
+	assert.Equal(t, bson.A{
+		bson.M{"path": "name", "kind": "changed", "old": "Alice", "new": "Bob"},
+		bson.M{"path": "password", "kind": "changed", "old": RedactedValue, "new": RedactedValue},
+	}, entries[1]["changes"])
+
+	assert.Equal(t, "", entries[2]["actor"])
+	assert.Equal(t, "DeleteOne", entries[2]["operation"])
+	assert.Len(t, entries[2]["changes"], 3)
+}
+
+func TestAuditTargetsSortedDocument(t *testing.T) {
+	m := useTestMemoryBackend(t)
+	EnableAudit(AuditOptions{Include: []string{"job"}})
+	defer DisableAudit()
+	assert.NoError(t, m.Seed("job",
+		bson.M{"_id": 1, "status": "queued", "priority": 1},
+		bson.M{"_id": 2, "status": "queued", "priority": 5},
+	))
+
+	// 按排序选中的文档才是被修改的文档，审计记录也应对应它
+	doc, err := FindOneAndUpdateWithContext(context.Background(), "job", bson.M{"status": "queued"}, bson.M{"status": "running"}, nil,
+		options.FindOneAndUpdate().SetSort(bson.D{{Key: "priority", Value: -1}}))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), doc["_id"])
+
+	entries := m.Documents(DefaultAuditCollection)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, int32(2), entries[0]["document_id"])
+	}
+	assert.Equal(t, "queued", m.Documents("job")[0]["status"])
+}
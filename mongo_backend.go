@@ -0,0 +1,92 @@
+package mongodb
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Backend 存储后端，中间件链的最内层通过它执行操作。
+// 返回值约定与 Handler 相同，错误应尽量与驱动一致，如未找到时返回 mongo.ErrNoDocuments
+type Backend interface {
+	FindOne(ctx context.Context, op *Operation) (bson.M, error)
+	FindList(ctx context.Context, op *Operation) ([]bson.M, error)
+	Count(ctx context.Context, op *Operation) (int64, error)
+	InsertOne(ctx context.Context, op *Operation) (*mongo.InsertOneResult, error)
+	// Update 处理 UpdateOne、UpdateMany、softDelete 和 ReplaceOne
+	Update(ctx context.Context, op *Operation) (*mongo.UpdateResult, error)
+	Delete(ctx context.Context, op *Operation) (*mongo.DeleteResult, error)
+	FindOneAndUpdate(ctx context.Context, op *Operation) (bson.M, error)
+}
+
+// mongoBackend 默认实现，访问 Connect 连接的数据库
+type mongoBackend struct{}
+
+func (mongoBackend) collection(ctx context.Context, op *Operation) *mongo.Collection {
+	return GetDatabaseWithContext(ctx).Collection(op.Collection)
+}
+
+func (b mongoBackend) FindOne(ctx context.Context, op *Operation) (bson.M, error) {
+	return findOne(ctx, b.collection(ctx, op), op)
+}
+
+func (b mongoBackend) FindList(ctx context.Context, op *Operation) ([]bson.M, error) {
+	return findList(ctx, b.collection(ctx, op), op)
+}
+
+func (b mongoBackend) Count(ctx context.Context, op *Operation) (int64, error) {
+	return countDocuments(ctx, b.collection(ctx, op), op)
+}
+
+func (b mongoBackend) InsertOne(ctx context.Context, op *Operation) (*mongo.InsertOneResult, error) {
+	return insertOne(ctx, b.collection(ctx, op), op)
+}
+
+func (b mongoBackend) Update(ctx context.Context, op *Operation) (*mongo.UpdateResult, error) {
+	return update(ctx, b.collection(ctx, op), op)
+}
+
+func (b mongoBackend) Delete(ctx context.Context, op *Operation) (*mongo.DeleteResult, error) {
+	return deleteDocuments(ctx, b.collection(ctx, op), op)
+}
+
+func (b mongoBackend) FindOneAndUpdate(ctx context.Context, op *Operation) (bson.M, error) {
+	return findOneAndUpdate(ctx, b.collection(ctx, op), op)
+}
+
+var (
+	backendMu sync.RWMutex
+	backend   Backend = mongoBackend{}
+)
+
+// SetBackend 设置存储后端，传 nil 恢复为 MongoDB
+func SetBackend(b Backend) {
+	backendMu.Lock()
+	defer backendMu.Unlock()
+	if b == nil {
+		b = mongoBackend{}
+	}
+	backend = b
+}
+
+// GetBackend 返回当前存储后端
+func GetBackend() Backend {
+	backendMu.RLock()
+	defer backendMu.RUnlock()
+	return backend
+}
+
+// UseMemoryBackend 切换到新的内存后端并返回，用于单元测试，不需要 Connect。
+// 索引、$jsonSchema 和 change stream 直接访问驱动，不经过 Backend；慢操作的 explain 返回 ExplainErr
+func UseMemoryBackend() *MemoryBackend {
+	m := NewMemoryBackend()
+	SetBackend(m)
+	return m
+}
+
+// UseMongoBackend 切换回 MongoDB 后端
+func UseMongoBackend() {
+	SetBackend(nil)
+}
@@ -0,0 +1,40 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Delete 删除数据 [start]
+// deleteType 为 DeleteOne 或 DeleteMany，软删除请使用 Update 的 softDelete
+func Delete(collectionName, deleteType string, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	return DeleteWithContext(context.Background(), collectionName, deleteType, filter, opts...)
+}
+
+// DeleteWithContext 同 Delete，ctx 用于取消和传递追踪信息
+func DeleteWithContext(ctx context.Context, collectionName, deleteType string, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	op := &Operation{Kind: OperationKind(deleteType), Collection: collectionName, Filter: filter, Options: toInterfaces(opts)}
+	switch op.Kind {
+	case KindDeleteOne, KindDeleteMany:
+	default:
+		return nil, fmt.Errorf("deleteType 参数错误")
+	}
+	result, err := execute(ctx, op)
+	return resultAs[*mongo.DeleteResult](op, result, err)
+}
+
+func deleteDocuments(ctx context.Context, collection *mongo.Collection, op *Operation) (*mongo.DeleteResult, error) {
+	opts, err := convertOpts[*options.DeleteOptions](op.Kind, op.Options)
+	if err != nil {
+		return nil, err
+	}
+	if op.Kind == KindDeleteMany {
+		return collection.DeleteMany(ctx, op.Filter, opts...)
+	}
+	return collection.DeleteOne(ctx, op.Filter, opts...)
+}
+
+// Delete 删除数据 [end]
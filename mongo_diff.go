@@ -0,0 +1,200 @@
+package mongodb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// DiffKind 差异类型
+type DiffKind string
+
+const (
+	DiffAdded   DiffKind = "added"
+	DiffRemoved DiffKind = "removed"
+	DiffChanged DiffKind = "changed"
+)
+
+// DiffEntry 一处差异，Path 为点路径，数组元素用下标表示
+type DiffEntry struct {
+	Path string
+	Kind DiffKind
+	Old  interface{}
+	New  interface{}
+}
+
+// BsonDiff DiffBson 的结果，按字段在文档中的顺序排列
+type BsonDiff []DiffEntry
+
+// DiffBson 比较两个文档，a 为旧文档，b 为新文档，支持 bson.M、bson.D、map 和结构体。
+// 嵌套文档逐层比较；长度相同的数组逐个元素比较，长度不同时整个数组记为 changed；
+// 数字按数值比较，bson.M 的字段按名称排序
+func DiffBson(a, b interface{}) (BsonDiff, error) {
+	da, err := diffDoc(a)
+	if err != nil {
+		return nil, err
+	}
+	db, err := diffDoc(b)
+	if err != nil {
+		return nil, err
+	}
+	diff := BsonDiff{}
+	diffDocs("", da, db, &diff)
+	return diff, nil
+}
+
+func diffDoc(v interface{}) (bson.D, error) {
+	if v == nil {
+		return bson.D{}, nil
+	}
+	n, err := diffNormalize(v)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := n.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("DiffBson 需要文档，实际为 %T", v)
+	}
+	return d, nil
+}
+
+// diffNormalize 文档统一为 bson.D（map 按键排序），数组统一为 bson.A，其它值经 BSON 编解码统一类型
+func diffNormalize(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case nil:
+		return nil, nil
+	case bson.D:
+		out := make(bson.D, 0, len(x))
+		for _, e := range x {
+			nv, err := diffNormalize(e.Value)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, bson.E{Key: e.Key, Value: nv})
+		}
+		return out, nil
+	case bson.M:
+		return diffNormalize(map[string]interface{}(x))
+	case map[string]interface{}:
+		d, _ := MapToBsonD(x)
+		return diffNormalize(d)
+	case bson.A:
+		return diffNormalize([]interface{}(x))
+	case []interface{}:
+		out := make(bson.A, 0, len(x))
+		for _, item := range x {
+			nv, err := diffNormalize(item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, nv)
+		}
+		return out, nil
+	}
+	nv, err := normalizeValue(v)
+	if err != nil {
+		return nil, err
+	}
+	switch nv.(type) {
+	case bson.M, bson.A:
+		return diffNormalize(nv)
+	}
+	return nv, nil
+}
+
+func diffDocs(prefix string, a, b bson.D, diff *BsonDiff) {
+	inB := make(map[string]interface{}, len(b))
+	for _, e := range b {
+		inB[e.Key] = e.Value
+	}
+	inA := make(map[string]bool, len(a))
+	for _, e := range a {
+		inA[e.Key] = true
+		path := prefix + e.Key
+		nv, ok := inB[e.Key]
+		if !ok {
+			*diff = append(*diff, DiffEntry{Path: path, Kind: DiffRemoved, Old: e.Value})
+			continue
+		}
+		diffValues(path, e.Value, nv, diff)
+	}
+	for _, e := range b {
+		if !inA[e.Key] {
+			*diff = append(*diff, DiffEntry{Path: prefix + e.Key, Kind: DiffAdded, New: e.Value})
+		}
+	}
+}
+
+func diffValues(path string, a, b interface{}, diff *BsonDiff) {
+	switch av := a.(type) {
+	case bson.D:
+		if bv, ok := b.(bson.D); ok {
+			diffDocs(path+".", av, bv, diff)
+			return
+		}
+	case bson.A:
+		if bv, ok := b.(bson.A); ok && len(av) == len(bv) {
+			for i := range av {
+				diffValues(path+"."+strconv.Itoa(i), av[i], bv[i], diff)
+			}
+			return
+		}
+	default:
+		if valuesEqual(a, b) {
+			return
+		}
+	}
+	*diff = append(*diff, DiffEntry{Path: path, Kind: DiffChanged, Old: a, New: b})
+}
+
+// Empty 两个文档是否相同
+func (d BsonDiff) Empty() bool {
+	return len(d) == 0
+}
+
+// String 每行一处差异：+ 新增，- 删除，~ 修改，值使用 Extended JSON
+func (d BsonDiff) String() string {
+	var b strings.Builder
+	for _, e := range d {
+		switch e.Kind {
+		case DiffAdded:
+			fmt.Fprintf(&b, "+ %s: %s\n", e.Path, diffValueString(e.New))
+		case DiffRemoved:
+			fmt.Fprintf(&b, "- %s: %s\n", e.Path, diffValueString(e.Old))
+		case DiffChanged:
+			fmt.Fprintf(&b, "~ %s: %s -> %s\n", e.Path, diffValueString(e.Old), diffValueString(e.New))
+		}
+	}
+	return b.String()
+}
+
+func diffValueString(v interface{}) string {
+	data, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: v}}, false, false)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	// 去掉外层的 {"v":...}
+	return string(data[5 : len(data)-1])
+}
+
+// ToUpdate 转为把 a 更新为 b 的更新文档：added 和 changed 为 $set，removed 为 $unset
+func (d BsonDiff) ToUpdate() bson.D {
+	set, unset := bson.D{}, bson.D{}
+	for _, e := range d {
+		if e.Kind == DiffRemoved {
+			unset = append(unset, bson.E{Key: e.Path, Value: ""})
+			continue
+		}
+		set = append(set, bson.E{Key: e.Path, Value: e.New})
+	}
+	update := bson.D{}
+	if len(set) > 0 {
+		update = append(update, bson.E{Key: "$set", Value: set})
+	}
+	if len(unset) > 0 {
+		update = append(update, bson.E{Key: "$unset", Value: unset})
+	}
+	return update
+}
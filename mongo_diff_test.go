@@ -0,0 +1,87 @@
+package mongodb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDiffBson(t *testing.T) {
+	oid := primitive.NewObjectID()
+	at := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	a := bson.D{
+		{Key: "_id", Value: oid},
+		{Key: "name", Value: "Alice"},
+		{Key: "age", Value: 30},
+		{Key: "address", Value: bson.M{"city": "Beijing", "zip": "100000"}},
+		{Key: "tags", Value: bson.A{"a", "b"}},
+		{Key: "items", Value: bson.A{bson.M{"n": 1}, bson.M{"n": 2}}},
+		{Key: "created", Value: at},
+	}
+	b := bson.M{
+		"_id":     oid,
+		"name":    "Bob",
+		"age":     int64(30),
+		"address": bson.D{{Key: "city", Value: "Shanghai"}},
+		"tags":    bson.A{"a", "b", "c"},
+		"items":   []interface{}{bson.M{"n": 1}, bson.M{"n": 3}},
+		"created": primitive.NewDateTimeFromTime(at),
+		"email":   "bob@example.com",
+	}
+
+	diff, err := DiffBson(a, b)
+	assert.NoError(t, err)
+	assert.Equal(t, BsonDiff{
+		{Path: "name", Kind: DiffChanged, Old: "Alice", New: "Bob"},
+		{Path: "address.city", Kind: DiffChanged, Old: "Beijing", New: "Shanghai"},
+		{Path: "address.zip", Kind: DiffRemoved, Old: "100000"},
+		{Path: "tags", Kind: DiffChanged, Old: bson.A{"a", "b"}, New: bson.A{"a", "b", "c"}},
+		{Path: "items.1.n", Kind: DiffChanged, Old: int32(2), New: int32(3)},
+		{Path: "email", Kind: DiffAdded, New: "bob@example.com"},
+	}, diff)
+
+	assert.Equal(t, `~ name: "Alice" -> "Bob"
+~ address.city: "Beijing" -> "Shanghai"
+- address.zip: "100000"
+~ tags: ["a","b"] -> ["a","b","c"]
+~ items.1.n: 2 -> 3
++ email: "bob@example.com"
+`, diff.String())
+
+	assert.Equal(t, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "name", Value: "Bob"},
+			{Key: "address.city", Value: "Shanghai"},
+			{Key: "tags", Value: bson.A{"a", "b", "c"}},
+			{Key: "items.1.n", Value: int32(3)},
+			{Key: "email", Value: "bob@example.com"},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "address.zip", Value: ""}}},
+	}, diff.ToUpdate())
+
+	same, err := DiffBson(a, a)
+	assert.NoError(t, err)
+	assert.True(t, same.Empty())
+
+	_, err = DiffBson(a, bson.A{1})
+	assert.Error(t, err)
+}
+
+func TestDiffBsonUpdate(t *testing.T) {
+	m := useTestMemoryBackend(t)
+	old := bson.M{"_id": 1, "name": "Alice", "profile": bson.M{"city": "Beijing", "age": 30}}
+	assert.NoError(t, m.Seed("user", old))
+
+	updated := bson.M{"_id": 1, "name": "Alice", "profile": bson.M{"city": "Shanghai"}, "vip": true}
+	diff, err := DiffBson(old, updated)
+	assert.NoError(t, err)
+	_, err = FindOneAndUpdate("user", bson.M{"_id": 1}, nil, diff.ToUpdate())
+	assert.NoError(t, err)
+
+	after, err := DiffBson(m.Documents("user")[0], updated)
+	assert.NoError(t, err)
+	assert.True(t, after.Empty(), after.String())
+}
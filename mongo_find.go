@@ -13,43 +13,61 @@ package mongodb
 import (
 	"context"
 
-	dhlog "github.com/lepingbeta/go-common-v2-dh-log"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // 查找一条数据 [start]
 func FindOne(collectionName string, filter interface{}, opts ...*options.FindOneOptions) (bson.M, error) {
-	collection := GetDatabase().Collection(collectionName)
-	ctx, cancel := context.WithTimeout(context.Background(), timeoutSec)
-	defer cancel()
+	return FindOneWithContext(context.Background(), collectionName, filter, opts...)
+}
+
+// FindOneWithContext 同 FindOne，ctx 用于取消和传递追踪信息
+func FindOneWithContext(ctx context.Context, collectionName string, filter interface{}, opts ...*options.FindOneOptions) (bson.M, error) {
+	op := &Operation{Kind: KindFindOne, Collection: collectionName, Filter: filter, Options: toInterfaces(opts)}
+	result, err := execute(ctx, op)
+	return resultAs[bson.M](op, result, err)
+}
+
+func findOne(ctx context.Context, collection *mongo.Collection, op *Operation) (bson.M, error) {
+	opts, err := convertOpts[*options.FindOneOptions](op.Kind, op.Options)
+	if err != nil {
+		return nil, err
+	}
 
 	// 构建查询条件
 	var result bson.M
-	err := collection.FindOne(ctx, filter, opts...).Decode(&result)
+	err = collection.FindOne(ctx, op.Filter, opts...).Decode(&result)
 	if err != nil {
-		dhlog.Info(err.Error())
 		return nil, err
 	}
-
-	// 处理查询结果
-	dhlog.Info("查询结果：", result)
-	return result, err
+	return result, nil
 }
 
 // 查找一条数据 [end]
 
 // 查找多条数据 [start]
 func FindList(collectionName string, filter interface{}, opts ...*options.FindOptions) ([]bson.M, error) {
-	collection := GetDatabase().Collection(collectionName)
-	ctx, cancel := context.WithTimeout(context.Background(), timeoutSec)
-	defer cancel()
+	return FindListWithContext(context.Background(), collectionName, filter, opts...)
+}
+
+// FindListWithContext 同 FindList，ctx 用于取消和传递追踪信息
+func FindListWithContext(ctx context.Context, collectionName string, filter interface{}, opts ...*options.FindOptions) ([]bson.M, error) {
+	op := &Operation{Kind: KindFindList, Collection: collectionName, Filter: filter, Options: toInterfaces(opts)}
+	result, err := execute(ctx, op)
+	return resultAs[[]bson.M](op, result, err)
+}
+
+func findList(ctx context.Context, collection *mongo.Collection, op *Operation) ([]bson.M, error) {
+	opts, err := convertOpts[*options.FindOptions](op.Kind, op.Options)
+	if err != nil {
+		return nil, err
+	}
 
 	// 构建查询条件
-	var result bson.M
-	cur, err := collection.Find(ctx, filter, opts...)
+	cur, err := collection.Find(ctx, op.Filter, opts...)
 	if err != nil {
-		dhlog.Info(err.Error())
 		return nil, err
 	}
 	defer cur.Close(ctx)
@@ -59,15 +77,12 @@ func FindList(collectionName string, filter interface{}, opts ...*options.FindOp
 		var result bson.M
 		err := cur.Decode(&result)
 		if err != nil {
-			dhlog.Error("解析文档失败：" + err.Error())
+			logf(LevelError, "解析文档失败："+err.Error(), Field{Key: "collection", Value: op.Collection})
 			continue
 		}
 		results = append(results, result)
 	}
-
-	// 处理查询结果
-	dhlog.Info("查询结果：", result)
-	return results, err
+	return results, nil
 }
 
 // 查找多条数据 [end]
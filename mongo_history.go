@@ -0,0 +1,282 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// HistorySuffix 历史集合名的后缀
+const HistorySuffix = "_history"
+
+// Revision 文档的一个历史版本，保存在 <collection>_history 中
+type Revision struct {
+	DocumentID interface{} `bson:"document_id"`
+	// Revision 从 1 开始递增
+	Revision int64 `bson:"revision"`
+	// Operation 结束该版本的操作
+	Operation string `bson:"operation"`
+	Actor     string `bson:"actor,omitempty"`
+	// Document 该版本的完整内容。为 nil 时是创建标记：此前文档不存在，Operation 为创建文档的操作
+	Document bson.M `bson:"document"`
+	// ValidFrom 该版本开始生效的时间，即上一个版本结束的时间，没有上一个版本时为 nil
+	ValidFrom *time.Time `bson:"valid_from,omitempty"`
+	// ValidTo 该版本被修改或删除的时间
+	ValidTo time.Time `bson:"valid_to"`
+}
+
+// HistoryOptions 历史版本配置
+type HistoryOptions struct {
+	// FailOnError 为 true 时写历史版本失败会作为操作的错误返回（数据已修改），否则只记录日志
+	FailOnError bool
+}
+
+var (
+	historyMu          sync.RWMutex
+	historyCollections = map[string]HistoryOptions{}
+)
+
+// EnableHistory 为集合开启历史版本：每次 Update、Replace、Delete 和 FindOneAndUpdate 修改文档后，
+// 将修改前的内容写入 <collection>_history；InsertOne 和 upsert 创建文档时写入创建标记。
+// 版本号通过计数器集合（见 SetCountersCollection）按文档原子分配，
+// 建议在历史集合上建立 document_id + revision 的唯一索引
+func EnableHistory(collectionName string, opts ...HistoryOptions) {
+	var o HistoryOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	historyCollections[collectionName] = o
+}
+
+// DisableHistory 关闭集合的历史版本，已有的历史保留
+func DisableHistory(collectionName string) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	delete(historyCollections, collectionName)
+}
+
+// HistoryCollection 返回集合对应的历史集合名
+func HistoryCollection(collectionName string) string {
+	return collectionName + HistorySuffix
+}
+
+// historyConfig 返回集合是否开启历史版本及其配置
+func historyConfig(collectionName string) (HistoryOptions, bool) {
+	historyMu.RLock()
+	defer historyMu.RUnlock()
+	o, ok := historyCollections[collectionName]
+	return o, ok
+}
+
+func historyMiddleware(next Handler) Handler {
+	return func(ctx context.Context, op *Operation) (interface{}, error) {
+		o, ok := historyConfig(op.Collection)
+		if !op.Kind.IsWrite() || !ok {
+			return next(ctx, op)
+		}
+
+		var before []bson.M
+		if op.Kind != KindInsertOne {
+			var err error
+			if before, err = targetImages(ctx, next, op); err != nil {
+				return nil, err
+			}
+		}
+		result, err := next(ctx, op)
+		// 数据已经写入，默认不因历史版本失败而返回错误，避免调用方重试已生效的修改
+		if herr := recordHistory(ctx, next, op, before, result, err); herr != nil {
+			logf(LevelError, "写入历史版本失败："+herr.Error(), Field{Key: "collection", Value: op.Collection})
+			if o.FailOnError {
+				return result, herr
+			}
+		}
+		return result, err
+	}
+}
+
+// recordHistory 按操作的结果写入历史版本，before 为修改前的文档
+func recordHistory(ctx context.Context, next Handler, op *Operation, before []bson.M, result interface{}, err error) error {
+	if op.Kind == KindInsertOne {
+		if res, ok := result.(*mongo.InsertOneResult); ok && err == nil {
+			return saveRevision(ctx, next, op, res.InsertedID, nil, time.Now())
+		}
+		return nil
+	}
+	if len(before) == 0 {
+		// 没有修改已有文档，检查是否 upsert 创建了文档
+		id, ok, uerr := upsertedID(ctx, next, op, result, err)
+		if uerr != nil || !ok {
+			return uerr
+		}
+		return saveRevision(ctx, next, op, id, nil, time.Now())
+	}
+	if err != nil {
+		return nil
+	}
+
+	deleted := op.Kind == KindDeleteOne || op.Kind == KindDeleteMany
+	var after map[interface{}]bson.M
+	if !deleted {
+		ids := make(bson.A, 0, len(before))
+		for _, d := range before {
+			ids = append(ids, d["_id"])
+		}
+		if after, err = imagesByID(ctx, next, op.Collection, ids); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	for _, d := range before {
+		if !deleted {
+			diff, err := DiffBson(d, after[idKey(d["_id"])])
+			if err != nil {
+				return err
+			}
+			if diff.Empty() {
+				continue
+			}
+		}
+		if err := saveRevision(ctx, next, op, d["_id"], d, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// upsertedID 返回 upsert 创建的文档的 _id。FindOneAndUpdate 返回修改前的文档时结果为 ErrNoDocuments，
+// 此时按过滤条件查找：执行前没有匹配的文档，执行后匹配的就是新创建的文档
+func upsertedID(ctx context.Context, next Handler, op *Operation, result interface{}, err error) (interface{}, bool, error) {
+	if res, ok := result.(*mongo.UpdateResult); ok && err == nil {
+		return res.UpsertedID, res.UpsertedID != nil, nil
+	}
+	if op.Kind != KindFindOneAndUpdate || (err != nil && !errors.Is(err, mongo.ErrNoDocuments)) {
+		return nil, false, nil
+	}
+	if doc, ok := result.(bson.M); ok && doc != nil {
+		return doc["_id"], true, nil
+	}
+	doc, ferr := next(ctx, &Operation{Kind: KindFindOne, Collection: op.Collection, Filter: op.Filter})
+	if errors.Is(ferr, mongo.ErrNoDocuments) {
+		return nil, false, nil
+	}
+	if ferr != nil {
+		return nil, false, ferr
+	}
+	return doc.(bson.M)["_id"], true, nil
+}
+
+// historyCounter 每个文档一个计数器，保存最后分配的版本号及其结束时间
+type historyCounter struct {
+	Seq     int64     `bson:"seq"`
+	ValidTo time.Time `bson:"valid_to"`
+}
+
+// saveRevision 写入一个历史版本，doc 为 nil 时写入创建标记。
+// 版本号由计数器原子分配，并发修改同一文档时不会重复
+func saveRevision(ctx context.Context, next Handler, op *Operation, id interface{}, doc bson.M, now time.Time) error {
+	historyName := HistoryCollection(op.Collection)
+	rev := Revision{
+		DocumentID: id,
+		Revision:   1,
+		Operation:  string(op.Kind),
+		Actor:      ActorFromContext(ctx),
+		Document:   doc,
+		ValidTo:    now,
+	}
+
+	// 返回更新前的计数器，同时得到上一个版本的结束时间
+	last, err := next(ctx, &Operation{
+		Kind:       KindFindOneAndUpdate,
+		Collection: getCountersCollection(),
+		Filter:     bson.M{"_id": bson.D{{Key: "collection", Value: historyName}, {Key: "document_id", Value: id}}},
+		Document:   bson.M{"valid_to": now},
+		Operators:  bson.D{{Key: "$inc", Value: bson.M{"seq": 1}}},
+		Options:    []interface{}{options.FindOneAndUpdate().SetUpsert(true)},
+	})
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+	case err != nil:
+		return err
+	default:
+		prev, err := decodeAs[historyCounter](ctx, last.(bson.M))
+		if err != nil {
+			return err
+		}
+		rev.Revision = prev.Seq + 1
+		validFrom := prev.ValidTo
+		rev.ValidFrom = &validFrom
+	}
+	_, err = next(ctx, &Operation{Kind: KindInsertOne, Collection: historyName, Document: rev})
+	return err
+}
+
+// historyID 与 Repository 一致，合法的 ObjectID 十六进制字符串转为 ObjectID
+func historyID(id interface{}) interface{} {
+	return idFilter(id)["_id"]
+}
+
+// ListRevisions 返回文档的全部历史版本和创建标记，按版本号升序，不包括当前版本
+func ListRevisions(ctx context.Context, collectionName string, id interface{}) ([]Revision, error) {
+	return FindListAs[Revision](ctx, HistoryCollection(collectionName),
+		bson.M{"document_id": historyID(id)},
+		options.Find().SetSort(bson.D{{Key: "revision", Value: 1}}))
+}
+
+// FindAsOf 返回文档在 at 时刻的内容，当时文档不存在（创建之前或删除之后）时返回 mongo.ErrNoDocuments。
+// 开启历史之前创建的文档没有创建标记，第一个历史版本之前的时间视为文档已存在
+func FindAsOf(ctx context.Context, collectionName string, id interface{}, at time.Time) (bson.M, error) {
+	docID := historyID(id)
+	rev, err := FindOneAs[Revision](ctx, HistoryCollection(collectionName),
+		bson.M{"document_id": docID, "valid_to": bson.M{"$gt": at}},
+		options.FindOne().SetSort(bson.D{{Key: "revision", Value: 1}}))
+	if err == nil {
+		if rev.Document == nil {
+			return nil, mongo.ErrNoDocuments
+		}
+		return rev.Document, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	// at 之后没有修改，当前版本即为当时的内容，已删除时同样返回 ErrNoDocuments
+	return FindOneWithContext(ctx, collectionName, bson.M{"_id": docID})
+}
+
+// RevertTo 用指定的历史版本替换当前文档，文档已删除时重新插入。
+// 替换本身也会产生一个新的历史版本；集合开启乐观锁时沿用当前的版本号
+func RevertTo(ctx context.Context, collectionName string, id interface{}, revision int64) (*mongo.UpdateResult, error) {
+	docID := historyID(id)
+	rev, err := FindOneAs[Revision](ctx, HistoryCollection(collectionName), bson.M{"document_id": docID, "revision": revision})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("mongodb: revision %d of %v not found in %s: %w", revision, docID, collectionName, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rev.Document == nil {
+		return nil, fmt.Errorf("mongodb: revision %d of %v in %s is a creation marker: %w", revision, docID, collectionName, mongo.ErrNoDocuments)
+	}
+
+	doc := rev.Document
+	if field := VersionField(collectionName); field != "" {
+		current, err := FindOneWithContext(ctx, collectionName, bson.M{"_id": docID})
+		switch {
+		case err == nil:
+			doc[field] = current[field]
+		case errors.Is(err, mongo.ErrNoDocuments):
+			delete(doc, field)
+		default:
+			return nil, err
+		}
+	}
+	return UpdateWithContext(ctx, collectionName, string(KindReplaceOne), bson.M{"_id": docID}, doc, options.Replace().SetUpsert(true))
+}
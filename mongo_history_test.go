@@ -0,0 +1,169 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestHistory(t *testing.T) {
+	m := useTestMemoryBackend(t)
+	EnableHistory("article")
+	defer DisableHistory("article")
+
+	ctx := WithActor(context.Background(), "editor")
+	// 各次修改之间的时间点，DateTime 精度为毫秒
+	tick := func() time.Time {
+		time.Sleep(5 * time.Millisecond)
+		at := time.Now()
+		time.Sleep(5 * time.Millisecond)
+		return at
+	}
+
+	t0 := tick()
+	_, err := InsertOneWithContext(ctx, "article", bson.M{"_id": 1, "title": "v1"})
+	assert.NoError(t, err)
+	t1 := tick()
+	_, err = UpdateWithContext(ctx, "article", string(KindUpdateOne), bson.M{"_id": 1}, bson.M{"title": "v2"})
+	assert.NoError(t, err)
+	// 没有变化时不产生版本
+	_, err = UpdateWithContext(ctx, "article", string(KindUpdateOne), bson.M{"_id": 1}, bson.M{"title": "v2"})
+	assert.NoError(t, err)
+	t2 := tick()
+	_, err = UpdateWithContext(ctx, "article", string(KindReplaceOne), bson.M{"_id": 1}, bson.M{"title": "v3"})
+	assert.NoError(t, err)
+	t3 := tick()
+	_, err = DeleteWithContext(ctx, "article", string(KindDeleteOne), bson.M{"_id": 1})
+	assert.NoError(t, err)
+	t4 := tick()
+
+	assert.Empty(t, m.Documents("article"))
+	revisions, err := ListRevisions(ctx, "article", 1)
+	assert.NoError(t, err)
+	if assert.Len(t, revisions, 4) {
+		assert.Equal(t, []string{"InsertOne", "UpdateOne", "ReplaceOne", "DeleteOne"},
+			[]string{revisions[0].Operation, revisions[1].Operation, revisions[2].Operation, revisions[3].Operation})
+		assert.Equal(t, int64(4), revisions[3].Revision)
+		assert.Equal(t, "editor", revisions[0].Actor)
+		// 创建标记
+		assert.Nil(t, revisions[0].Document)
+		assert.Nil(t, revisions[0].ValidFrom)
+		assert.Equal(t, revisions[0].ValidTo, *revisions[1].ValidFrom)
+	}
+
+	for at, title := range map[time.Time]string{t1: "v1", t2: "v2", t3: "v3"} {
+		doc, err := FindAsOf(ctx, "article", 1, at)
+		if assert.NoError(t, err) {
+			assert.Equal(t, title, doc["title"])
+		}
+	}
+	// 创建之前和删除之后文档不存在
+	_, err = FindAsOf(ctx, "article", 1, t0)
+	assert.True(t, errors.Is(err, mongo.ErrNoDocuments))
+	_, err = FindAsOf(ctx, "article", 1, t4)
+	assert.True(t, errors.Is(err, mongo.ErrNoDocuments))
+
+	// 恢复已删除的文档，upsert 写入新的创建标记
+	res, err := RevertTo(ctx, "article", 1, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), res.UpsertedID)
+	assert.Equal(t, []bson.M{{"_id": int32(1), "title": "v2"}}, m.Documents("article"))
+	t5 := tick()
+
+	_, err = UpdateWithContext(ctx, "article", string(KindUpdateOne), bson.M{"_id": 1}, bson.M{"title": "v4"})
+	assert.NoError(t, err)
+	revisions, err = ListRevisions(ctx, "article", 1)
+	assert.NoError(t, err)
+	if assert.Len(t, revisions, 6) {
+		assert.Nil(t, revisions[4].Document)
+		assert.Equal(t, "ReplaceOne", revisions[4].Operation)
+		assert.Equal(t, revisions[3].ValidTo, *revisions[4].ValidFrom)
+		assert.Equal(t, "v2", revisions[5].Document["title"])
+	}
+	// 删除期间仍然不存在
+	_, err = FindAsOf(ctx, "article", 1, t4)
+	assert.True(t, errors.Is(err, mongo.ErrNoDocuments))
+	doc, err := FindAsOf(ctx, "article", 1, t5)
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", doc["title"])
+	doc, err = FindAsOf(ctx, "article", 1, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "v4", doc["title"])
+
+	// 不能恢复到创建标记
+	_, err = RevertTo(ctx, "article", 1, 1)
+	assert.True(t, errors.Is(err, mongo.ErrNoDocuments))
+	_, err = RevertTo(ctx, "article", 1, 9)
+	assert.True(t, errors.Is(err, mongo.ErrNoDocuments))
+	// FindOneAndUpdate upsert 返回修改前的文档（ErrNoDocuments）时同样写入创建标记
+	_, err = FindOneAndUpdateWithContext(ctx, "article", bson.M{"_id": 2}, bson.M{"title": "new"}, nil, options.FindOneAndUpdate().SetUpsert(true))
+	assert.True(t, errors.Is(err, mongo.ErrNoDocuments))
+	revisions, err = ListRevisions(ctx, "article", 2)
+	assert.NoError(t, err)
+	if assert.Len(t, revisions, 1) {
+		assert.Nil(t, revisions[0].Document)
+	}
+
+	// 未开启的集合不记录
+	_, err = InsertOneWithContext(ctx, "comment", bson.M{"_id": 1})
+	assert.NoError(t, err)
+	_, err = DeleteWithContext(ctx, "comment", string(KindDeleteOne), bson.M{"_id": 1})
+	assert.NoError(t, err)
+	assert.Empty(t, m.Documents(HistoryCollection("comment")))
+}
+
+func TestHistoryConcurrentRevisions(t *testing.T) {
+	useTestMemoryBackend(t)
+	EnableHistory("article")
+	defer DisableHistory("article")
+
+	_, err := InsertOne("article", bson.M{"_id": 1, "n": 0})
+	assert.NoError(t, err)
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := UpdateWithContext(context.Background(), "article", string(KindUpdateOne), bson.M{"_id": 1}, bson.M{"n": i})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	// 版本号由计数器分配，不重复也不遗漏
+	revisions, err := ListRevisions(context.Background(), "article", 1)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 21)
+	for i, r := range revisions {
+		assert.Equal(t, int64(i+1), r.Revision)
+	}
+}
+
+func TestHistoryFailOnError(t *testing.T) {
+	m := useTestMemoryBackend(t)
+	EnableHistory("article")
+	defer DisableHistory("article")
+	// 计数器损坏，写历史版本失败
+	assert.NoError(t, m.Seed(DefaultCountersCollection, bson.M{
+		"_id": bson.D{{Key: "collection", Value: HistoryCollection("article")}, {Key: "document_id", Value: 1}},
+		"seq": "broken",
+	}))
+
+	// 默认只记录日志，数据已写入，不返回错误
+	_, err := InsertOne("article", bson.M{"_id": 1, "title": "v1"})
+	assert.NoError(t, err)
+	assert.Len(t, m.Documents("article"), 1)
+
+	EnableHistory("article", HistoryOptions{FailOnError: true})
+	_, err = UpdateWithContext(context.Background(), "article", string(KindUpdateOne), bson.M{"_id": 1}, bson.M{"title": "v2"})
+	assert.Error(t, err)
+	assert.Equal(t, "v2", m.Documents("article")[0]["title"])
+	assert.Empty(t, m.Documents(HistoryCollection("article")))
+}
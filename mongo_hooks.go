@@ -0,0 +1,142 @@
+package mongodb
+
+import (
+	"context"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BeforeInserter 插入前调用，可以设置默认值或拒绝插入
+type BeforeInserter interface {
+	BeforeInsert(ctx context.Context) error
+}
+
+// BeforeUpdater 更新前调用
+type BeforeUpdater interface {
+	BeforeUpdate(ctx context.Context) error
+}
+
+// AfterFinder 查询解码后调用
+type AfterFinder interface {
+	AfterFind(ctx context.Context) error
+}
+
+// Validator 插入和更新前，在 BeforeInsert/BeforeUpdate 和标签校验之后调用
+type Validator interface {
+	Validate() error
+}
+
+// hookTarget 返回可以调用钩子的值：传入的是结构体值而钩子定义在指针上时，
+// 复制一份到新指针上，返回的 doc 应替换原文档使用
+func hookTarget(doc interface{}) interface{} {
+	if doc == nil {
+		return nil
+	}
+	switch doc.(type) {
+	case BeforeInserter, BeforeUpdater, AfterFinder, Validator:
+		return doc
+	}
+	v := reflect.ValueOf(doc)
+	if v.Kind() != reflect.Struct {
+		return doc
+	}
+	ptr := reflect.New(v.Type())
+	ptr.Elem().Set(v)
+	switch ptr.Interface().(type) {
+	case BeforeInserter, BeforeUpdater, AfterFinder, Validator:
+		return ptr.Interface()
+	}
+	return doc
+}
+
+// runBeforeInsert 依次调用 BeforeInsert 和 Validate，返回应当写入的文档
+func runBeforeInsert(ctx context.Context, doc interface{}) (interface{}, error) {
+	doc = hookTarget(doc)
+	if h, ok := doc.(BeforeInserter); ok {
+		if err := h.BeforeInsert(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if err := runValidate(ctx, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// runBeforeUpdate 依次调用 BeforeUpdate 和 Validate，返回应当写入的文档
+func runBeforeUpdate(ctx context.Context, doc interface{}) (interface{}, error) {
+	doc = hookTarget(doc)
+	if h, ok := doc.(BeforeUpdater); ok {
+		if err := h.BeforeUpdate(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if err := runValidate(ctx, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// runValidate 先按 validate 标签校验，再调用 Validate，ctx 经 SkipValidation 处理时跳过
+func runValidate(ctx context.Context, doc interface{}) error {
+	if validationSkipped(ctx) {
+		return nil
+	}
+	if err := ValidateStruct(doc); err != nil {
+		return err
+	}
+	if v, ok := doc.(Validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+func runAfterFind(ctx context.Context, doc interface{}) error {
+	if h, ok := doc.(AfterFinder); ok {
+		return h.AfterFind(ctx)
+	}
+	return nil
+}
+
+// FindOneAs 查找一条数据并解码为 T，T 实现 AfterFinder 时自动调用
+func FindOneAs[T any](ctx context.Context, collectionName string, filter interface{}, opts ...*options.FindOneOptions) (*T, error) {
+	m, err := FindOneWithContext(ctx, collectionName, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return decodeAs[T](ctx, m)
+}
+
+// FindListAs 查找多条数据并解码为 []T，T 实现 AfterFinder 时对每条数据调用
+func FindListAs[T any](ctx context.Context, collectionName string, filter interface{}, opts ...*options.FindOptions) ([]T, error) {
+	list, err := FindListWithContext(ctx, collectionName, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]T, 0, len(list))
+	for _, m := range list {
+		doc, err := decodeAs[T](ctx, m)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, *doc)
+	}
+	return results, nil
+}
+
+func decodeAs[T any](ctx context.Context, m bson.M) (*T, error) {
+	data, err := bson.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	doc := new(T)
+	if err := bson.Unmarshal(data, doc); err != nil {
+		return nil, err
+	}
+	if err := runAfterFind(ctx, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
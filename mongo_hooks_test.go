@@ -0,0 +1,81 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type hookUser struct {
+	Name   string `bson:"name"`
+	Status string `bson:"status"`
+	Loaded bool   `bson:"-"`
+}
+
+func (u *hookUser) BeforeInsert(ctx context.Context) error {
+	if u.Status == "" {
+		u.Status = "active"
+	}
+	return nil
+}
+
+func (u *hookUser) BeforeUpdate(ctx context.Context) error {
+	u.Status = "updated"
+	return nil
+}
+
+func (u *hookUser) AfterFind(ctx context.Context) error {
+	u.Loaded = true
+	return nil
+}
+
+func (u *hookUser) Validate() error {
+	if u.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+// stubOperations 拦截所有操作，记录最后一次操作并返回 result
+func stubOperations(result interface{}) *Operation {
+	last := &Operation{}
+	Use(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) (interface{}, error) {
+			*last = *op
+			return result, nil
+		}
+	})
+	return last
+}
+
+func TestLifecycleHooks(t *testing.T) {
+	defer ResetMiddlewares()
+	last := stubOperations(&mongo.InsertOneResult{})
+
+	// 传入结构体值时也会调用指针上的钩子
+	_, err := InsertOneWithCreateTime("user", hookUser{Name: "Alice"})
+	assert.NoError(t, err)
+	doc := last.Document.(bson.D)
+	assert.Equal(t, bson.E{Key: "status", Value: "active"}, doc[1])
+	assert.Equal(t, "create_time", doc[2].Key)
+
+	_, err = InsertOne("user", &hookUser{})
+	assert.EqualError(t, err, "name is required")
+
+	ResetMiddlewares()
+	last = stubOperations(&mongo.UpdateResult{})
+	_, err = UpdateWithUpdateTime("user", "UpdateOne", bson.M{}, hookUser{Name: "Bob"})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.E{Key: "status", Value: "updated"}, last.Document.(bson.D)[1])
+
+	ResetMiddlewares()
+	stubOperations(bson.M{"name": "Carol"})
+	u, err := FindOneAs[hookUser](context.Background(), "user", bson.M{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Carol", u.Name)
+	assert.True(t, u.Loaded)
+}
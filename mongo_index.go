@@ -0,0 +1,503 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IndexDef 声明式的索引定义
+type IndexDef struct {
+	// Name 索引名，为空时与驱动一致按字段生成，如 name_1_age_-1
+	Name string
+	// Keys 索引字段，格式同 ParseSortString，如 "name=1,age=-1"
+	Keys   string
+	Unique bool
+	Sparse bool
+	// PartialFilter 部分索引的过滤条件
+	PartialFilter interface{}
+	// TTL 大于 0 时为 TTL 索引，按秒取整
+	TTL       time.Duration
+	Collation *options.Collation
+}
+
+// IndexAction 索引变更动作
+type IndexAction struct {
+	Collection string
+	Name       string
+	// Action 为 create、drop、recreate 或 unchanged
+	Action string
+	Reason string
+}
+
+// IndexReport EnsureIndexes 的执行报告，DryRun 时只包含计划而不执行
+type IndexReport struct {
+	DryRun  bool
+	Actions []IndexAction
+}
+
+// Changed 是否有需要变更的索引
+func (r *IndexReport) Changed() bool {
+	for _, a := range r.Actions {
+		if a.Action != "unchanged" {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *IndexReport) String() string {
+	var sb strings.Builder
+	if r.DryRun {
+		sb.WriteString("[dry-run]\n")
+	}
+	for _, a := range r.Actions {
+		fmt.Fprintf(&sb, "%-9s %s.%s", a.Action, a.Collection, a.Name)
+		if a.Reason != "" {
+			fmt.Fprintf(&sb, " (%s)", a.Reason)
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// EnsureIndexOptions EnsureIndexes 的选项
+type EnsureIndexOptions struct {
+	// DryRun 只生成报告，不修改数据库
+	DryRun bool
+	// DropUnknown 删除未声明的索引（_id_ 除外）
+	DropUnknown bool
+}
+
+var (
+	indexMu   sync.RWMutex
+	indexDefs = map[string][]IndexDef{}
+)
+
+// RegisterIndexes 为集合声明索引，重复调用会追加
+func RegisterIndexes(collectionName string, defs ...IndexDef) {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	indexDefs[collectionName] = append(indexDefs[collectionName], defs...)
+}
+
+// RegisteredIndexes 返回所有已声明的索引
+func RegisteredIndexes() map[string][]IndexDef {
+	indexMu.RLock()
+	defer indexMu.RUnlock()
+	out := make(map[string][]IndexDef, len(indexDefs))
+	for k, v := range indexDefs {
+		out[k] = append([]IndexDef(nil), v...)
+	}
+	return out
+}
+
+// EnsureIndexes 对所有已声明索引的集合执行 EnsureCollectionIndexes
+func EnsureIndexes(ctx context.Context, opts EnsureIndexOptions) (*IndexReport, error) {
+	defs := RegisteredIndexes()
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	report := &IndexReport{DryRun: opts.DryRun}
+	for _, name := range names {
+		r, err := EnsureCollectionIndexes(ctx, name, defs[name], opts)
+		if r != nil {
+			report.Actions = append(report.Actions, r.Actions...)
+		}
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// EnsureCollectionIndexes 比较声明的索引和已有索引：缺少的创建，定义不同的重建，
+// 开启 DropUnknown 时删除未声明的索引。先创建、再重建、最后删除，某一步失败时尽量保留已有的索引，
+// 重建见 recreateIndex
+func EnsureCollectionIndexes(ctx context.Context, collectionName string, defs []IndexDef, opts EnsureIndexOptions) (*IndexReport, error) {
+	view := openIndexView(collectionName)
+	existing, err := view.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report, models, err := planIndexes(collectionName, defs, existing, opts)
+	if err != nil || opts.DryRun {
+		return report, err
+	}
+
+	byName := map[string]mongo.IndexModel{}
+	for _, m := range models {
+		byName[*m.Options.Name] = m
+	}
+	specs := map[string]bson.D{}
+	for _, spec := range existing {
+		if name, ok := lookupD(spec, "name"); ok {
+			specs[fmt.Sprint(name)] = spec
+		}
+	}
+	for _, a := range report.Actions {
+		if a.Action != "create" {
+			continue
+		}
+		if err := view.CreateOne(ctx, byName[a.Name]); err != nil {
+			return report, fmt.Errorf("创建索引 %s.%s 失败: %w", collectionName, a.Name, err)
+		}
+	}
+	for _, a := range report.Actions {
+		if a.Action != "recreate" {
+			continue
+		}
+		if err := recreateIndex(ctx, view, byName[a.Name], specs[a.Name]); err != nil {
+			return report, fmt.Errorf("重建索引 %s.%s 失败: %w", collectionName, a.Name, err)
+		}
+	}
+	for _, a := range report.Actions {
+		if a.Action != "drop" {
+			continue
+		}
+		if err := view.DropOne(ctx, a.Name); err != nil {
+			return report, fmt.Errorf("删除索引 %s.%s 失败: %w", collectionName, a.Name, err)
+		}
+	}
+	return report, nil
+}
+
+// indexTempSuffix 重建索引时临时索引名的后缀
+const indexTempSuffix = "__ensure_tmp"
+
+// recreateIndex 先以临时名创建新索引，确认新定义可用（如唯一索引没有重复数据）后再替换旧索引，
+// 替换期间由临时索引保证约束。键相同的索引一般不能共存，无法使用临时名时改为删除后创建，
+// 创建失败时按 old 恢复旧索引，恢复也失败时返回的错误中说明旧索引已删除
+func recreateIndex(ctx context.Context, view indexView, model mongo.IndexModel, old bson.D) error {
+	name := *model.Options.Name
+	tmpName := name + indexTempSuffix
+	tmpOpts := *model.Options
+	tmpOpts.Name = &tmpName
+
+	err := view.CreateOne(ctx, mongo.IndexModel{Keys: model.Keys, Options: &tmpOpts})
+	if err == nil {
+		if err := view.DropOne(ctx, name); err != nil {
+			if derr := view.DropOne(ctx, tmpName); derr != nil {
+				logf(LevelError, "删除临时索引失败："+derr.Error(), Field{Key: "index", Value: tmpName})
+			}
+			return err
+		}
+		if err := view.CreateOne(ctx, model); err != nil {
+			return fmt.Errorf("旧索引已删除，新定义保留在临时索引 %s 中: %w", tmpName, err)
+		}
+		if err := view.DropOne(ctx, tmpName); err != nil {
+			return fmt.Errorf("删除临时索引 %s 失败: %w", tmpName, err)
+		}
+		return nil
+	}
+	if !isIndexConflict(err) {
+		// 新定义不可用，旧索引保持不变
+		return err
+	}
+
+	if err := view.DropOne(ctx, name); err != nil {
+		return err
+	}
+	if err := view.CreateOne(ctx, model); err != nil {
+		if rerr := view.CreateOne(ctx, indexModelFromSpec(old)); rerr != nil {
+			return fmt.Errorf("旧索引已删除且未能恢复（%v）: %w", rerr, err)
+		}
+		return fmt.Errorf("已恢复旧索引: %w", err)
+	}
+	return nil
+}
+
+// isIndexConflict 已有键相同的索引（IndexOptionsConflict、IndexKeySpecsConflict）
+func isIndexConflict(err error) bool {
+	var se mongo.ServerError
+	return errors.As(err, &se) && (se.HasErrorCode(85) || se.HasErrorCode(86))
+}
+
+// indexModelFromSpec 按 listIndexes 返回的定义生成 IndexModel，只包含 IndexDef 支持的选项
+func indexModelFromSpec(spec bson.D) mongo.IndexModel {
+	keys, _ := lookupD(spec, "key")
+	name, _ := lookupD(spec, "name")
+	opts := options.Index().SetName(fmt.Sprint(name))
+	if specBool(spec, "unique") {
+		opts.SetUnique(true)
+	}
+	if specBool(spec, "sparse") {
+		opts.SetSparse(true)
+	}
+	if ttl, ok := lookupD(spec, "expireAfterSeconds"); ok {
+		opts.SetExpireAfterSeconds(int32(toInt64(ttl)))
+	}
+	if partial, ok := lookupD(spec, "partialFilterExpression"); ok {
+		opts.SetPartialFilterExpression(partial)
+	}
+	if c, ok := lookupD(spec, "collation"); ok {
+		c, _ := c.(bson.D)
+		locale, _ := lookupD(c, "locale")
+		strength, _ := lookupD(c, "strength")
+		caseLevel, _ := lookupD(c, "caseLevel")
+		caseFirst, _ := lookupD(c, "caseFirst")
+		numeric, _ := lookupD(c, "numericOrdering")
+		alternate, _ := lookupD(c, "alternate")
+		maxVariable, _ := lookupD(c, "maxVariable")
+		normalization, _ := lookupD(c, "normalization")
+		backwards, _ := lookupD(c, "backwards")
+		collation := &options.Collation{Locale: fmt.Sprint(locale), Strength: int(toInt64(strength))}
+		collation.CaseLevel, _ = caseLevel.(bool)
+		collation.CaseFirst, _ = caseFirst.(string)
+		collation.NumericOrdering, _ = numeric.(bool)
+		collation.Alternate, _ = alternate.(string)
+		collation.MaxVariable, _ = maxVariable.(string)
+		collation.Normalization, _ = normalization.(bool)
+		collation.Backwards, _ = backwards.(bool)
+		opts.SetCollation(collation)
+	}
+	return mongo.IndexModel{Keys: keys, Options: opts}
+}
+
+// indexView 索引操作，测试时替换 openIndexView
+type indexView interface {
+	List(ctx context.Context) ([]bson.D, error)
+	CreateOne(ctx context.Context, model mongo.IndexModel) error
+	DropOne(ctx context.Context, name string) error
+}
+
+var openIndexView = func(collectionName string) indexView {
+	return driverIndexView{GetDatabase().Collection(collectionName).Indexes()}
+}
+
+// driverIndexView 通过驱动访问索引
+type driverIndexView struct {
+	view mongo.IndexView
+}
+
+func (v driverIndexView) List(ctx context.Context) ([]bson.D, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeoutSec)
+	defer cancel()
+	cur, err := v.view.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var specs []bson.D
+	if err := cur.All(ctx, &specs); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+func (v driverIndexView) CreateOne(ctx context.Context, model mongo.IndexModel) error {
+	_, err := v.view.CreateOne(ctx, model)
+	return err
+}
+
+func (v driverIndexView) DropOne(ctx context.Context, name string) error {
+	_, err := v.view.DropOne(ctx, name)
+	return err
+}
+
+// planIndexes 生成变更计划和需要创建的索引
+func planIndexes(collectionName string, defs []IndexDef, existing []bson.D, opts EnsureIndexOptions) (*IndexReport, []mongo.IndexModel, error) {
+	report := &IndexReport{DryRun: opts.DryRun}
+	var models []mongo.IndexModel
+
+	byName := map[string]bson.D{}
+	for _, spec := range existing {
+		if name, ok := lookupD(spec, "name"); ok {
+			byName[fmt.Sprint(name)] = spec
+		}
+	}
+
+	declared := map[string]bool{}
+	for _, def := range defs {
+		model, name, err := def.model()
+		if err != nil {
+			return nil, nil, fmt.Errorf("索引 %s 定义错误: %w", collectionName, err)
+		}
+		declared[name] = true
+		spec, ok := byName[name]
+		switch {
+		case !ok:
+			report.Actions = append(report.Actions, IndexAction{Collection: collectionName, Name: name, Action: "create"})
+			models = append(models, model)
+		default:
+			if reason := def.diff(spec); reason != "" {
+				report.Actions = append(report.Actions, IndexAction{Collection: collectionName, Name: name, Action: "recreate", Reason: reason})
+				models = append(models, model)
+			} else {
+				report.Actions = append(report.Actions, IndexAction{Collection: collectionName, Name: name, Action: "unchanged"})
+			}
+		}
+	}
+
+	if opts.DropUnknown {
+		names := make([]string, 0, len(byName))
+		for name := range byName {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if name == "_id_" || declared[name] {
+				continue
+			}
+			report.Actions = append(report.Actions, IndexAction{Collection: collectionName, Name: name, Action: "drop", Reason: "未声明"})
+		}
+	}
+	return report, models, nil
+}
+
+// IndexName 按驱动的规则生成索引名
+func IndexName(keys bson.D) string {
+	parts := make([]string, 0, len(keys)*2)
+	for _, k := range keys {
+		parts = append(parts, k.Key, fmt.Sprint(k.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+func (def IndexDef) model() (mongo.IndexModel, string, error) {
+	keys, err := ParseSortStringD(def.Keys)
+	if err != nil {
+		return mongo.IndexModel{}, "", err
+	}
+	name := def.Name
+	if name == "" {
+		name = IndexName(keys)
+	}
+	opts := options.Index().SetName(name)
+	if def.Unique {
+		opts.SetUnique(true)
+	}
+	if def.Sparse {
+		opts.SetSparse(true)
+	}
+	if def.PartialFilter != nil {
+		opts.SetPartialFilterExpression(def.PartialFilter)
+	}
+	if def.TTL > 0 {
+		opts.SetExpireAfterSeconds(int32(def.TTL / time.Second))
+	}
+	if def.Collation != nil {
+		opts.SetCollation(def.Collation)
+	}
+	return mongo.IndexModel{Keys: keys, Options: opts}, name, nil
+}
+
+// diff 比较已有索引与定义，相同时返回空字符串
+func (def IndexDef) diff(spec bson.D) string {
+	keys, _ := ParseSortStringD(def.Keys)
+	existingKeys, _ := lookupD(spec, "key")
+	if !sameKeys(keys, existingKeys) {
+		return "keys 不同"
+	}
+	if def.Unique != specBool(spec, "unique") {
+		return "unique 不同"
+	}
+	if def.Sparse != specBool(spec, "sparse") {
+		return "sparse 不同"
+	}
+	ttl, hasTTL := lookupD(spec, "expireAfterSeconds")
+	if (def.TTL > 0) != hasTTL || (hasTTL && toInt64(ttl) != int64(def.TTL/time.Second)) {
+		return "TTL 不同"
+	}
+	partial, _ := lookupD(spec, "partialFilterExpression")
+	if !reflect.DeepEqual(canonical(def.PartialFilter), canonical(partial)) {
+		return "partialFilterExpression 不同"
+	}
+	collation, hasCollation := lookupD(spec, "collation")
+	if (def.Collation != nil) != hasCollation {
+		return "collation 不同"
+	}
+	if def.Collation != nil {
+		c, _ := collation.(bson.D)
+		locale, _ := lookupD(c, "locale")
+		strength, _ := lookupD(c, "strength")
+		if fmt.Sprint(locale) != def.Collation.Locale ||
+			(def.Collation.Strength != 0 && toInt64(strength) != int64(def.Collation.Strength)) {
+			return "collation 不同"
+		}
+	}
+	return ""
+}
+
+func sameKeys(keys bson.D, existing interface{}) bool {
+	ek, ok := existing.(bson.D)
+	if !ok || len(ek) != len(keys) {
+		return false
+	}
+	for i := range keys {
+		if keys[i].Key != ek[i].Key || toInt64(keys[i].Value) != toInt64(ek[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func specBool(spec bson.D, key string) bool {
+	v, _ := lookupD(spec, key)
+	b, _ := v.(bool)
+	return b
+}
+
+// canonical 将文档转为键有序的 bson.D，数字统一为 float64，用于比较
+func canonical(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case bson.M:
+		return canonicalMap(val)
+	case map[string]interface{}:
+		return canonicalMap(val)
+	case bson.D:
+		m := make(map[string]interface{}, len(val))
+		for _, e := range val {
+			m[e.Key] = e.Value
+		}
+		return canonicalMap(m)
+	case bson.A:
+		return canonicalSlice(val)
+	case []interface{}:
+		return canonicalSlice(val)
+	case int, int32, int64, float32, float64:
+		return toFloat64(val)
+	default:
+		return v
+	}
+}
+
+func canonicalMap(m map[string]interface{}) bson.D {
+	keys := sortByMapKeys(m)
+	out := make(bson.D, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, bson.E{Key: k, Value: canonical(m[k])})
+	}
+	return out
+}
+
+func canonicalSlice(items []interface{}) bson.A {
+	out := make(bson.A, len(items))
+	for i, item := range items {
+		out[i] = canonical(item)
+	}
+	return out
+}
+
+func toFloat64(v interface{}) float64 {
+	switch f := v.(type) {
+	case float64:
+		return f
+	case float32:
+		return float64(f)
+	}
+	return float64(toInt64(v))
+}
@@ -0,0 +1,148 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestPlanIndexes(t *testing.T) {
+	defs := []IndexDef{
+		{Keys: "email=1", Unique: true},
+		{Keys: "tenant_id=1,create_time=-1"},
+		{Name: "session_ttl", Keys: "expire_at=1", TTL: time.Hour},
+		{Keys: "status=1", PartialFilter: bson.M{"deleted": false, "status": bson.M{"$exists": true}}},
+		{Keys: "name=1", Collation: &options.Collation{Locale: "zh", Strength: 2}},
+	}
+	existing := []bson.D{
+		{{Key: "v", Value: int32(2)}, {Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}}, {Key: "name", Value: "_id_"}},
+		{{Key: "key", Value: bson.D{{Key: "email", Value: int32(1)}}}, {Key: "name", Value: "email_1"}},
+		{{Key: "key", Value: bson.D{{Key: "expire_at", Value: int32(1)}}}, {Key: "name", Value: "session_ttl"}, {Key: "expireAfterSeconds", Value: int32(3600)}},
+		{{Key: "key", Value: bson.D{{Key: "status", Value: int32(1)}}}, {Key: "name", Value: "status_1"},
+			{Key: "partialFilterExpression", Value: bson.D{{Key: "status", Value: bson.D{{Key: "$exists", Value: true}}}, {Key: "deleted", Value: false}}}},
+		{{Key: "key", Value: bson.D{{Key: "name", Value: int32(1)}}}, {Key: "name", Value: "name_1"},
+			{Key: "collation", Value: bson.D{{Key: "locale", Value: "zh"}, {Key: "strength", Value: int32(2)}}}},
+		{{Key: "key", Value: bson.D{{Key: "legacy", Value: int32(1)}}}, {Key: "name", Value: "legacy_1"}},
+	}
+
+	report, models, err := planIndexes("user", defs, existing, EnsureIndexOptions{DryRun: true, DropUnknown: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []IndexAction{
+		{Collection: "user", Name: "email_1", Action: "recreate", Reason: "unique 不同"},
+		{Collection: "user", Name: "tenant_id_1_create_time_-1", Action: "create"},
+		{Collection: "user", Name: "session_ttl", Action: "unchanged"},
+		{Collection: "user", Name: "status_1", Action: "unchanged"},
+		{Collection: "user", Name: "name_1", Action: "unchanged"},
+		{Collection: "user", Name: "legacy_1", Action: "drop", Reason: "未声明"},
+	}, report.Actions)
+	assert.True(t, report.Changed())
+	assert.Len(t, models, 2)
+	assert.Equal(t, bson.D{{Key: "tenant_id", Value: 1}, {Key: "create_time", Value: -1}}, models[1].Keys)
+	assert.Contains(t, report.String(), "create    user.tenant_id_1_create_time_-1")
+
+	_, _, err = planIndexes("user", []IndexDef{{Keys: "bad"}}, nil, EnsureIndexOptions{})
+	assert.Error(t, err)
+}
+
+// fakeIndexView 记录索引操作，createErr 返回创建指定名称的索引时的错误
+type fakeIndexView struct {
+	specs     []bson.D
+	calls     []string
+	createErr func(name string) error
+}
+
+func (v *fakeIndexView) List(ctx context.Context) ([]bson.D, error) { return v.specs, nil }
+
+func (v *fakeIndexView) CreateOne(ctx context.Context, model mongo.IndexModel) error {
+	name := *model.Options.Name
+	v.calls = append(v.calls, "create "+name)
+	if v.createErr != nil {
+		return v.createErr(name)
+	}
+	return nil
+}
+
+func (v *fakeIndexView) DropOne(ctx context.Context, name string) error {
+	v.calls = append(v.calls, "drop "+name)
+	return nil
+}
+
+func useFakeIndexView(t *testing.T, v *fakeIndexView) {
+	prev := openIndexView
+	t.Cleanup(func() { openIndexView = prev })
+	openIndexView = func(string) indexView { return v }
+}
+
+func TestEnsureCollectionIndexes(t *testing.T) {
+	ctx := context.Background()
+	existing := []bson.D{
+		{{Key: "key", Value: bson.D{{Key: "email", Value: int32(1)}}}, {Key: "name", Value: "email_1"}},
+		{{Key: "key", Value: bson.D{{Key: "legacy", Value: int32(1)}}}, {Key: "name", Value: "legacy_1"}},
+	}
+	defs := []IndexDef{{Keys: "email=1", Unique: true}, {Keys: "name=1"}}
+	opts := EnsureIndexOptions{DropUnknown: true}
+	conflict := mongo.CommandError{Code: 85, Message: "Index already exists with a different name"}
+	duplicate := errors.New("E11000 duplicate key")
+
+	// 先以临时名创建，成功后替换旧索引
+	v := &fakeIndexView{specs: existing}
+	useFakeIndexView(t, v)
+	_, err := EnsureCollectionIndexes(ctx, "user", defs, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"create name_1",
+		"create email_1__ensure_tmp", "drop email_1", "create email_1", "drop email_1__ensure_tmp",
+		"drop legacy_1",
+	}, v.calls)
+
+	// 新定义不可用（如存在重复数据）时旧索引保持不变，也不继续删除
+	v = &fakeIndexView{specs: existing, createErr: func(name string) error {
+		if strings.HasPrefix(name, "email_1") {
+			return duplicate
+		}
+		return nil
+	}}
+	useFakeIndexView(t, v)
+	_, err = EnsureCollectionIndexes(ctx, "user", defs, opts)
+	assert.ErrorIs(t, err, duplicate)
+	assert.Equal(t, []string{"create name_1", "create email_1__ensure_tmp"}, v.calls)
+
+	// 不能使用临时名时删除后创建，失败则恢复旧索引
+	var attempts int
+	v = &fakeIndexView{specs: existing, createErr: func(name string) error {
+		switch {
+		case name == "email_1__ensure_tmp":
+			return conflict
+		case name == "email_1":
+			attempts++
+			if attempts == 1 {
+				return duplicate
+			}
+		}
+		return nil
+	}}
+	useFakeIndexView(t, v)
+	_, err = EnsureCollectionIndexes(ctx, "user", defs[:1], EnsureIndexOptions{})
+	assert.ErrorIs(t, err, duplicate)
+	assert.Contains(t, err.Error(), "已恢复旧索引")
+	assert.Equal(t, []string{"create email_1__ensure_tmp", "drop email_1", "create email_1", "create email_1"}, v.calls)
+
+	// 恢复也失败时说明旧索引已删除
+	v = &fakeIndexView{specs: existing, createErr: func(name string) error {
+		if name == "email_1__ensure_tmp" {
+			return conflict
+		}
+		return duplicate
+	}}
+	useFakeIndexView(t, v)
+	_, err = EnsureCollectionIndexes(ctx, "user", defs[:1], EnsureIndexOptions{})
+	assert.Contains(t, err.Error(), "user.email_1")
+	assert.Contains(t, err.Error(), "旧索引已删除且未能恢复")
+}
@@ -14,7 +14,6 @@ import (
 	"context"
 	"time"
 
-	dhlog "github.com/lepingbeta/go-common-v2-dh-log"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -22,25 +21,51 @@ import (
 
 // InsertOne 插入一条数据 [start]
 func InsertOne(collectionName string, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
-	collection := GetDatabase().Collection(collectionName)
-	ctx, cancel := context.WithTimeout(context.Background(), timeoutSec)
-	defer cancel()
-	return collection.InsertOne(ctx, document, opts...)
+	return InsertOneWithContext(context.Background(), collectionName, document, opts...)
+}
+
+// InsertOneWithContext 同 InsertOne，ctx 用于取消和传递追踪信息。
+// document 实现 BeforeInserter/Validator 时会在插入前调用
+func InsertOneWithContext(ctx context.Context, collectionName string, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	document, err := runBeforeInsert(ctx, document)
+	if err != nil {
+		return nil, err
+	}
+	op := &Operation{Kind: KindInsertOne, Collection: collectionName, Document: document, Options: toInterfaces(opts)}
+	result, err := execute(ctx, op)
+	return resultAs[*mongo.InsertOneResult](op, result, err)
+}
+
+func insertOne(ctx context.Context, collection *mongo.Collection, op *Operation) (*mongo.InsertOneResult, error) {
+	opts, err := convertOpts[*options.InsertOneOptions](op.Kind, op.Options)
+	if err != nil {
+		return nil, err
+	}
+	return collection.InsertOne(ctx, op.Document, opts...)
 }
 
 func InsertOneBsonD(collectionName string, document bson.D, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
-	document = append(document, bson.E{Key: "create_time", Value: time.Now().Format("2006-01-02 15:04:05")})
+	document = append(document, bson.E{Key: "create_time", Value: time.Now().Format(TimeLayout)})
 	return InsertOne(collectionName, document, opts...)
 }
 
 func InsertOneWithCreateTime(collectionName string, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	return InsertOneWithCreateTimeContext(context.Background(), collectionName, document, opts...)
+}
+
+// InsertOneWithCreateTimeContext 同 InsertOneWithCreateTime，钩子在追加 create_time 之前调用
+func InsertOneWithCreateTimeContext(ctx context.Context, collectionName string, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	document, err := runBeforeInsert(ctx, document)
+	if err != nil {
+		return nil, err
+	}
 	bsonD, err := Struct2BsonD(document)
 	if err != nil {
-		dhlog.Error(err.Error())
+		logf(LevelError, "转换文档失败："+err.Error(), Field{Key: "collection", Value: collectionName})
 		return nil, err
 	}
-	document = append(bsonD, bson.E{Key: "create_time", Value: time.Now().Format("2006-01-02 15:04:05")})
-	return InsertOne(collectionName, document, opts...)
+	document = append(bsonD, bson.E{Key: "create_time", Value: time.Now().Format(TimeLayout)})
+	return InsertOneWithContext(ctx, collectionName, document, opts...)
 }
 
 // InsertOne 插入一条数据 [end]
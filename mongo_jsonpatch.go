@@ -0,0 +1,338 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrPatchPath 补丁中的路径无法转换为字段路径
+var ErrPatchPath = errors.New("mongodb: invalid patch path")
+
+// MergePatchToUpdate 将 RFC 7396 JSON Merge Patch 转为更新文档：
+// 对象逐层展开为点路径，null 转为 $unset，其它值（包括数组）转为 $set。
+// 与 RFC 不同，目标字段不是对象时展开的点路径会导致更新失败，而不是整体替换
+func MergePatchToUpdate(patch []byte) (bson.D, error) {
+	doc, err := parsePatchJSON(patch)
+	if err != nil {
+		return nil, err
+	}
+	root, ok := doc.(bson.D)
+	if !ok {
+		return nil, errors.New("mongodb: merge patch must be a JSON object")
+	}
+	set, unset := bson.D{}, bson.D{}
+	if err := flattenMergePatch(root, "", &set, &unset); err != nil {
+		return nil, err
+	}
+	update := bson.D{}
+	if len(set) > 0 {
+		update = append(update, bson.E{Key: "$set", Value: set})
+	}
+	if len(unset) > 0 {
+		update = append(update, bson.E{Key: "$unset", Value: unset})
+	}
+	return update, nil
+}
+
+func flattenMergePatch(doc bson.D, prefix string, set, unset *bson.D) error {
+	for _, e := range doc {
+		if err := checkPatchKey(e.Key); err != nil {
+			return err
+		}
+		path := prefix + e.Key
+		switch v := e.Value.(type) {
+		case nil:
+			*unset = append(*unset, bson.E{Key: path, Value: ""})
+		case bson.D:
+			if err := flattenMergePatch(v, path+".", set, unset); err != nil {
+				return err
+			}
+		default:
+			*set = append(*set, bson.E{Key: path, Value: v})
+		}
+	}
+	return nil
+}
+
+// parsePatchJSON 按 Extended JSON 解析，保留字段顺序，{"$oid": ...} 等会转为对应类型
+func parsePatchJSON(data []byte) (interface{}, error) {
+	var wrapper struct {
+		V interface{} `bson:"v"`
+	}
+	wrapped := append(append([]byte(`{"v":`), data...), '}')
+	if err := bson.UnmarshalExtJSON(wrapped, false, &wrapper); err != nil {
+		return nil, err
+	}
+	return wrapper.V, nil
+}
+
+// checkPatchKey 字段名不能以 $ 开头或包含 .，避免注入操作符或越级修改
+func checkPatchKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "$") || strings.Contains(key, ".") {
+		return fmt.Errorf("%w: %q", ErrPatchPath, key)
+	}
+	return nil
+}
+
+// JSONPatchOperation RFC 6902 中的一个操作
+type JSONPatchOperation struct {
+	Op    string      `bson:"op"`
+	Path  string      `bson:"path"`
+	From  string      `bson:"from"`
+	Value interface{} `bson:"value"`
+}
+
+// JSONPatchUpdate JSON Patch 转换的结果
+type JSONPatchUpdate struct {
+	// Conditions 由 test 操作转换的过滤条件，只作用于第一个更新
+	Conditions bson.D
+	// Updates 按顺序执行的更新文档。同一路径上的操作在一次更新中会冲突，
+	// 此时拆分为多个更新，例如先修改数组元素再向同一数组插入
+	Updates []bson.D
+}
+
+// JSONPatchToUpdate 将 RFC 6902 JSON Patch 转为更新文档：
+// add 到普通字段为 $set，到数组末尾（/-）为 $push，到数组下标为带 $position 的 $push；
+// remove 为 $unset（不支持数组元素：更新操作符无法只删除指定下标的元素）；replace 为 $set；
+// move 为 $rename（不支持数组元素）；test 转为过滤条件；不支持 copy。
+// 路径中的数字段视为数组下标
+func JSONPatchToUpdate(patch []byte) (*JSONPatchUpdate, error) {
+	parsed, err := parsePatchJSON(patch)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := parsed.(bson.A)
+	if !ok {
+		return nil, errors.New("mongodb: JSON patch must be an array")
+	}
+
+	b := &patchBuilder{result: &JSONPatchUpdate{Conditions: bson.D{}}}
+	for i, item := range list {
+		raw, err := bson.Marshal(item)
+		if err != nil {
+			return nil, fmt.Errorf("mongodb: JSON patch operation %d: %w", i, err)
+		}
+		var op JSONPatchOperation
+		if err := bson.Unmarshal(raw, &op); err != nil {
+			return nil, fmt.Errorf("mongodb: JSON patch operation %d: %w", i, err)
+		}
+		if err := b.apply(op); err != nil {
+			return nil, fmt.Errorf("mongodb: JSON patch operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	b.flush()
+	return b.result, nil
+}
+
+// patchBuilder 将操作合并到当前更新，路径冲突时开始新的更新
+type patchBuilder struct {
+	result  *JSONPatchUpdate
+	current bson.D
+	touched []string
+}
+
+func (b *patchBuilder) apply(op JSONPatchOperation) error {
+	segments, err := pointerSegments(op.Path)
+	if err != nil {
+		return err
+	}
+	path := strings.Join(segments, ".")
+	last := segments[len(segments)-1]
+	parent := strings.Join(segments[:len(segments)-1], ".")
+	index, isIndex := arrayIndex(last)
+
+	switch op.Op {
+	case "add":
+		switch {
+		case last == "-" && parent != "":
+			b.add(parent, "$push", parent, op.Value)
+		case isIndex && parent != "":
+			b.add(parent, "$push", parent, bson.D{{Key: "$each", Value: bson.A{op.Value}}, {Key: "$position", Value: index}})
+		default:
+			b.add(path, "$set", path, op.Value)
+		}
+	case "replace":
+		b.add(path, "$set", path, op.Value)
+	case "remove":
+		if isIndex && parent != "" {
+			// $unset 后 $pull null 会同时删除数组中原有的 null 元素
+			return errors.New("remove of array elements is not supported")
+		}
+		b.add(path, "$unset", path, "")
+	case "move":
+		from, err := pointerSegments(op.From)
+		if err != nil {
+			return err
+		}
+		for _, s := range append(from, segments...) {
+			if _, ok := arrayIndex(s); ok {
+				return errors.New("move of array elements is not supported")
+			}
+		}
+		fromPath := strings.Join(from, ".")
+		b.addMany([]string{fromPath, path}, "$rename", fromPath, path)
+	case "test":
+		if len(b.result.Updates) > 0 || len(b.current) > 0 {
+			return errors.New("test must come before other operations")
+		}
+		b.result.Conditions = append(b.result.Conditions, bson.E{Key: path, Value: op.Value})
+	case "copy":
+		return errors.New("copy is not supported")
+	default:
+		return fmt.Errorf("unknown op %q", op.Op)
+	}
+	return nil
+}
+
+func (b *patchBuilder) add(touch, operator, path string, value interface{}) {
+	b.addMany([]string{touch}, operator, path, value)
+}
+
+func (b *patchBuilder) addMany(touch []string, operator, path string, value interface{}) {
+	for _, t := range touch {
+		if b.conflicts(t) {
+			b.flush()
+			break
+		}
+	}
+	b.touched = append(b.touched, touch...)
+	for i, e := range b.current {
+		if e.Key == operator {
+			b.current[i].Value = append(e.Value.(bson.D), bson.E{Key: path, Value: value})
+			return
+		}
+	}
+	b.current = append(b.current, bson.E{Key: operator, Value: bson.D{{Key: path, Value: value}}})
+}
+
+// conflicts 路径与已修改的路径相同或互为前缀
+func (b *patchBuilder) conflicts(path string) bool {
+	for _, t := range b.touched {
+		if t == path || strings.HasPrefix(t, path+".") || strings.HasPrefix(path, t+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *patchBuilder) flush() {
+	if len(b.current) > 0 {
+		b.result.Updates = append(b.result.Updates, b.current)
+	}
+	b.current, b.touched = nil, nil
+}
+
+// pointerSegments 将 JSON Pointer 转为字段路径的各段
+func pointerSegments(pointer string) ([]string, error) {
+	if pointer == "" || !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: %q", ErrPatchPath, pointer)
+	}
+	segments := strings.Split(pointer[1:], "/")
+	for i, s := range segments {
+		s = strings.ReplaceAll(strings.ReplaceAll(s, "~1", "/"), "~0", "~")
+		if s != "-" || i != len(segments)-1 {
+			if err := checkPatchKey(s); err != nil {
+				return nil, err
+			}
+		}
+		segments[i] = s
+	}
+	return segments, nil
+}
+
+func arrayIndex(segment string) (int, bool) {
+	n, err := strconv.Atoi(segment)
+	return n, err == nil && n >= 0 && strconv.Itoa(n) == segment
+}
+
+// ApplyMergePatch 按 JSON Merge Patch 更新一条文档并写入 update_time
+func ApplyMergePatch(collectionName string, filter interface{}, patch []byte) (*mongo.UpdateResult, error) {
+	return ApplyMergePatchWithContext(context.Background(), collectionName, filter, patch)
+}
+
+// ApplyMergePatchWithContext 同 ApplyMergePatch，ctx 用于取消和传递追踪信息
+func ApplyMergePatchWithContext(ctx context.Context, collectionName string, filter interface{}, patch []byte) (*mongo.UpdateResult, error) {
+	update, err := MergePatchToUpdate(patch)
+	if err != nil {
+		return nil, err
+	}
+	return updateWithPatch(ctx, collectionName, filter, update)
+}
+
+// ApplyJSONPatch 按 JSON Patch 更新一条文档并写入 update_time。
+// test 操作加入过滤条件，条件不满足时 MatchedCount 为 0；
+// 转换出多个更新时依次执行，不是原子的：先按 filter 确定文档的 _id，之后的更新按 _id 执行，
+// 不受前面修改了 filter 中字段的影响；返回最后一次的结果
+func ApplyJSONPatch(collectionName string, filter interface{}, patch []byte) (*mongo.UpdateResult, error) {
+	return ApplyJSONPatchWithContext(context.Background(), collectionName, filter, patch)
+}
+
+// ApplyJSONPatchWithContext 同 ApplyJSONPatch，ctx 用于取消和传递追踪信息
+func ApplyJSONPatchWithContext(ctx context.Context, collectionName string, filter interface{}, patch []byte) (*mongo.UpdateResult, error) {
+	p, err := JSONPatchToUpdate(patch)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Conditions) > 0 {
+		filter = andFilter(filter, p.Conditions)
+	}
+	if len(p.Updates) <= 1 {
+		update := bson.D{}
+		if len(p.Updates) == 1 {
+			update = p.Updates[0]
+		}
+		return updateWithPatch(ctx, collectionName, filter, update)
+	}
+
+	// 先确定文档的 _id，第一次更新仍带上 filter 以检查 test 条件
+	doc, err := FindOneWithContext(ctx, collectionName, filter, options.FindOne().SetProjection(bson.M{"_id": 1}))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return &mongo.UpdateResult{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// _id 取自数据库，不需要 idFilter 的转换
+	byID := bson.M{"_id": doc["_id"]}
+
+	var result *mongo.UpdateResult
+	for i, update := range p.Updates {
+		f := interface{}(byID)
+		if i == 0 {
+			f = andFilter(filter, byID)
+		}
+		result, err = updateWithPatch(ctx, collectionName, f, update)
+		if err != nil {
+			return nil, err
+		}
+		if result.MatchedCount == 0 {
+			return result, nil
+		}
+	}
+	return result, nil
+}
+
+// updateWithPatch 执行转换后的更新，$set 中追加 update_time
+func updateWithPatch(ctx context.Context, collectionName string, filter interface{}, update bson.D) (*mongo.UpdateResult, error) {
+	set := bson.D{}
+	operators := bson.D{}
+	for _, e := range update {
+		if e.Key == "$set" {
+			set = e.Value.(bson.D)
+			continue
+		}
+		operators = append(operators, e)
+	}
+	set = append(removeD(set, "update_time"), bson.E{Key: "update_time", Value: time.Now().Format(TimeLayout)})
+	op := &Operation{Kind: KindUpdateOne, Collection: collectionName, Filter: filter, Document: set, Operators: operators}
+	result, err := execute(ctx, op)
+	return resultAs[*mongo.UpdateResult](op, result, err)
+}
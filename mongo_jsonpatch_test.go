@@ -0,0 +1,121 @@
+package mongodb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMergePatchToUpdate(t *testing.T) {
+	update, err := MergePatchToUpdate([]byte(`{"name": "Bob", "address": {"city": "Shanghai", "zip": null}, "tags": ["a"], "avatar": null}`))
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "name", Value: "Bob"},
+			{Key: "address.city", Value: "Shanghai"},
+			{Key: "tags", Value: bson.A{"a"}},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "address.zip", Value: ""}, {Key: "avatar", Value: ""}}},
+	}, update)
+
+	for _, bad := range []string{`[1]`, `{"$where": 1}`, `{"a.b": 1}`, `{"a": {"$gt": 1}}`, `{`} {
+		_, err := MergePatchToUpdate([]byte(bad))
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestJSONPatchToUpdate(t *testing.T) {
+	p, err := JSONPatchToUpdate([]byte(`[
+		{"op": "test", "path": "/version", "value": 3},
+		{"op": "replace", "path": "/name", "value": "Bob"},
+		{"op": "add", "path": "/tags/-", "value": "c"},
+		{"op": "add", "path": "/a~1b", "value": 1},
+		{"op": "remove", "path": "/legacy"},
+		{"op": "move", "from": "/old", "path": "/new"},
+		{"op": "add", "path": "/tags/0", "value": "z"}
+	]`))
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "version", Value: int32(3)}}, p.Conditions)
+	assert.Equal(t, []bson.D{
+		{
+			{Key: "$set", Value: bson.D{{Key: "name", Value: "Bob"}, {Key: "a/b", Value: int32(1)}}},
+			{Key: "$push", Value: bson.D{{Key: "tags", Value: "c"}}},
+			{Key: "$unset", Value: bson.D{{Key: "legacy", Value: ""}}},
+			{Key: "$rename", Value: bson.D{{Key: "old", Value: "new"}}},
+		},
+		{
+			{Key: "$push", Value: bson.D{{Key: "tags", Value: bson.D{{Key: "$each", Value: bson.A{"z"}}, {Key: "$position", Value: 0}}}}},
+		},
+	}, p.Updates)
+
+	for _, bad := range []string{
+		`{}`,
+		`[{"op": "copy", "from": "/a", "path": "/b"}]`,
+		`[{"op": "add", "path": "name", "value": 1}]`,
+		`[{"op": "add", "path": "/$set", "value": 1}]`,
+		`[{"op": "move", "from": "/a/0", "path": "/b"}]`,
+		`[{"op": "remove", "path": "/items/1"}]`,
+		`[{"op": "replace", "path": "/a", "value": 1}, {"op": "test", "path": "/a", "value": 1}]`,
+	} {
+		_, err := JSONPatchToUpdate([]byte(bad))
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	m := useTestMemoryBackend(t)
+	assert.NoError(t, m.Seed("user", bson.M{
+		"_id": 1, "name": "Alice", "version": 3,
+		"address": bson.M{"city": "Beijing", "zip": "100000"},
+		"items":   bson.A{"x", "y", "z"},
+	}))
+
+	res, err := ApplyMergePatch("user", bson.M{"_id": 1}, []byte(`{"address": {"city": "Shanghai", "zip": null}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), res.ModifiedCount)
+	doc := m.Documents("user")[0]
+	assert.Equal(t, bson.M{"city": "Shanghai"}, doc["address"])
+	assert.NotEmpty(t, doc["update_time"])
+
+	res, err = ApplyJSONPatch("user", bson.M{"_id": 1}, []byte(`[
+		{"op": "test", "path": "/version", "value": 3},
+		{"op": "replace", "path": "/items/1", "value": "y2"},
+		{"op": "add", "path": "/items/0", "value": "w"}
+	]`))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), res.MatchedCount)
+	assert.Equal(t, bson.A{"w", "x", "y2", "z"}, m.Documents("user")[0]["items"])
+
+	// test 不满足时不更新
+	res, err = ApplyJSONPatch("user", bson.M{"_id": 1}, []byte(`[
+		{"op": "test", "path": "/version", "value": 4},
+		{"op": "replace", "path": "/name", "value": "Bob"}
+	]`))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), res.MatchedCount)
+	assert.Equal(t, "Alice", m.Documents("user")[0]["name"])
+
+	// 前面的更新修改了 filter 中的字段，后续更新按 _id 执行
+	assert.NoError(t, m.Seed("task", bson.M{"_id": 1, "status": "open", "items": bson.A{"a", "b"}}))
+	res, err = ApplyJSONPatch("task", bson.M{"status": "open"}, []byte(`[
+		{"op": "replace", "path": "/status", "value": "done"},
+		{"op": "add", "path": "/items/-", "value": "c"},
+		{"op": "add", "path": "/items/0", "value": "z"}
+	]`))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), res.MatchedCount)
+	task := m.Documents("task")[0]
+	assert.Equal(t, "done", task["status"])
+	assert.Equal(t, bson.A{"z", "a", "b", "c"}, task["items"])
+
+	res, err = ApplyJSONPatch("task", bson.M{"status": "open"}, []byte(`[{"op": "add", "path": "/items/-", "value": "d"}, {"op": "add", "path": "/items/0", "value": "e"}]`))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), res.MatchedCount)
+
+	// 按下标删除会误删数组中原有的 null 元素，直接拒绝
+	assert.NoError(t, m.Seed("list", bson.M{"_id": 1, "items": bson.A{nil, "a", "b"}}))
+	_, err = ApplyJSONPatch("list", bson.M{"_id": 1}, []byte(`[{"op": "remove", "path": "/items/1"}]`))
+	assert.Error(t, err)
+	assert.Equal(t, bson.A{nil, "a", "b"}, m.Documents("list")[0]["items"])
+}
@@ -0,0 +1,274 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	dhlog "github.com/lepingbeta/go-common-v2-dh-log"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// LogLevel 日志级别
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	// LevelOff 关闭日志
+	LevelOff
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	case LevelOff:
+		return "OFF"
+	default:
+		return fmt.Sprintf("LEVEL(%d)", int(l))
+	}
+}
+
+// Field 结构化日志字段
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Logger 可插拔的日志接口
+type Logger interface {
+	Log(level LogLevel, msg string, fields ...Field)
+}
+
+// dhlogLogger 默认实现，输出到 dhlog
+type dhlogLogger struct{}
+
+func (dhlogLogger) Log(level LogLevel, msg string, fields ...Field) {
+	args := make([]any, 0, len(fields)*2)
+	for _, f := range fields {
+		args = append(args, f.Key, f.Value)
+	}
+	switch level {
+	case LevelError:
+		dhlog.Error(msg, args...)
+	case LevelWarn:
+		dhlog.Warn(msg, args...)
+	default:
+		dhlog.Info(msg, args...)
+	}
+}
+
+// NewDhlogLogger 返回输出到 dhlog 的 Logger（默认）
+func NewDhlogLogger() Logger {
+	return dhlogLogger{}
+}
+
+// slogLogger 将日志转发到 log/slog
+type slogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlogLogger 返回 log/slog 适配器，l 为 nil 时使用 slog.Default()
+func NewSlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &slogLogger{l: l}
+}
+
+func (s *slogLogger) Log(level LogLevel, msg string, fields ...Field) {
+	var sl slog.Level
+	switch level {
+	case LevelDebug:
+		sl = slog.LevelDebug
+	case LevelWarn:
+		sl = slog.LevelWarn
+	case LevelError:
+		sl = slog.LevelError
+	default:
+		sl = slog.LevelInfo
+	}
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, f := range fields {
+		attrs = append(attrs, slog.Any(f.Key, f.Value))
+	}
+	s.l.LogAttrs(context.Background(), sl, msg, attrs...)
+}
+
+// RedactRule 脱敏规则，path 为点分隔的字段路径，返回 true 表示需要替换为返回值
+type RedactRule func(path string, value interface{}) (interface{}, bool)
+
+// RedactedValue 脱敏后的占位值
+const RedactedValue = "***"
+
+// RedactKeys 按字段名脱敏（不区分大小写），key 可以是字段名或完整的点路径
+func RedactKeys(keys ...string) RedactRule {
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[strings.ToLower(k)] = struct{}{}
+	}
+	return func(path string, value interface{}) (interface{}, bool) {
+		p := strings.ToLower(path)
+		if _, ok := set[p]; ok {
+			return RedactedValue, true
+		}
+		if i := strings.LastIndex(p, "."); i >= 0 {
+			if _, ok := set[p[i+1:]]; ok {
+				return RedactedValue, true
+			}
+		}
+		return nil, false
+	}
+}
+
+// LogOptions 日志配置
+type LogOptions struct {
+	// Level 最低输出级别，成功的操作以 Debug 级别输出
+	Level LogLevel
+	// MetadataOnly 为 true 时只记录元信息，不输出过滤条件、文档和查询结果
+	MetadataOnly bool
+	// RedactRules 输出文档前依次应用的脱敏规则
+	RedactRules []RedactRule
+}
+
+// DefaultLogOptions 默认只输出 Info 及以上级别，且不记录文档内容
+func DefaultLogOptions() LogOptions {
+	return LogOptions{
+		Level:        LevelInfo,
+		MetadataOnly: true,
+	}
+}
+
+var (
+	logMu      sync.RWMutex
+	logger     Logger = dhlogLogger{}
+	logOptions        = DefaultLogOptions()
+)
+
+// SetLogger 设置日志实现，传 nil 恢复为 dhlog
+func SetLogger(l Logger) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	if l == nil {
+		l = dhlogLogger{}
+	}
+	logger = l
+}
+
+// SetLogOptions 设置日志配置
+func SetLogOptions(o LogOptions) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	logOptions = o
+}
+
+// GetLogOptions 返回当前日志配置
+func GetLogOptions() LogOptions {
+	logMu.RLock()
+	defer logMu.RUnlock()
+	return logOptions
+}
+
+// SetLogLevel 设置最低输出级别
+func SetLogLevel(level LogLevel) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	logOptions.Level = level
+}
+
+func currentLogger() (Logger, LogOptions) {
+	logMu.RLock()
+	defer logMu.RUnlock()
+	return logger, logOptions
+}
+
+// logf 按当前配置输出一条日志
+func logf(level LogLevel, msg string, fields ...Field) {
+	l, o := currentLogger()
+	if level < o.Level || o.Level >= LevelOff {
+		return
+	}
+	l.Log(level, msg, fields...)
+}
+
+// Redact 按规则对文档做脱敏，返回新的值，不修改原始数据
+func Redact(v interface{}, rules ...RedactRule) interface{} {
+	if len(rules) == 0 {
+		return v
+	}
+	return redactValue("", v, rules)
+}
+
+func redactValue(path string, v interface{}, rules []RedactRule) interface{} {
+	if path != "" {
+		for _, rule := range rules {
+			if nv, ok := rule(path, v); ok {
+				return nv
+			}
+		}
+	}
+	switch val := v.(type) {
+	case bson.M:
+		out := bson.M{}
+		for k, item := range val {
+			out[k] = redactValue(joinPath(path, k), item, rules)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = redactValue(joinPath(path, k), item, rules)
+		}
+		return out
+	case bson.D:
+		out := make(bson.D, 0, len(val))
+		for _, e := range val {
+			out = append(out, bson.E{Key: e.Key, Value: redactValue(joinPath(path, e.Key), e.Value, rules)})
+		}
+		return out
+	case bson.A:
+		out := make(bson.A, len(val))
+		for i, item := range val {
+			out[i] = redactValue(path, item, rules)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = redactValue(path, item, rules)
+		}
+		return out
+	case []bson.M:
+		out := make([]bson.M, len(val))
+		for i, item := range val {
+			out[i], _ = redactValue(path, item, rules).(bson.M)
+		}
+		return out
+	case nil, string, bool, int, int32, int64, float64:
+		return v
+	default:
+		// 结构体等类型先转为 bson.M 再脱敏
+		if m, err := Struct2BsonM(v); err == nil {
+			return redactValue(path, m, rules)
+		}
+		return v
+	}
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
@@ -0,0 +1,104 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type captureLogger struct {
+	entries []captureEntry
+}
+
+type captureEntry struct {
+	level  LogLevel
+	msg    string
+	fields map[string]interface{}
+}
+
+func (c *captureLogger) Log(level LogLevel, msg string, fields ...Field) {
+	m := map[string]interface{}{}
+	for _, f := range fields {
+		m[f.Key] = f.Value
+	}
+	c.entries = append(c.entries, captureEntry{level: level, msg: msg, fields: m})
+}
+
+func TestRedact(t *testing.T) {
+	doc := bson.M{
+		"name":     "Alice",
+		"password": "secret",
+		"profile": bson.D{
+			{Key: "phone", Value: "123456"},
+			{Key: "city", Value: "Wonderland"},
+		},
+		"contacts": bson.A{bson.M{"phone": "654321"}},
+	}
+
+	got := Redact(doc, RedactKeys("password", "phone")).(bson.M)
+	assert.Equal(t, "Alice", got["name"])
+	assert.Equal(t, RedactedValue, got["password"])
+	assert.Equal(t, bson.D{
+		{Key: "phone", Value: RedactedValue},
+		{Key: "city", Value: "Wonderland"},
+	}, got["profile"])
+	assert.Equal(t, bson.A{bson.M{"phone": RedactedValue}}, got["contacts"])
+
+	// 原始数据不应被修改
+	assert.Equal(t, "secret", doc["password"])
+}
+
+func TestOpRecordLog(t *testing.T) {
+	c := &captureLogger{}
+	SetLogger(c)
+	defer SetLogger(nil)
+	defer SetLogOptions(DefaultLogOptions())
+
+	// 默认配置下成功的操作不输出
+	rec, _ := startOp(context.Background(), "user", "FindOne", bson.M{"email": "a@b.c"}, nil)
+	rec.Result = bson.M{"email": "a@b.c"}
+	rec.finish(nil)
+	assert.Len(t, c.entries, 0)
+
+	// 失败的操作只输出元信息
+	rec, _ = startOp(context.Background(), "user", "FindOne", bson.M{"email": "a@b.c"}, nil)
+	rec.finish(errors.New("boom"))
+	assert.Len(t, c.entries, 1)
+	assert.Equal(t, LevelError, c.entries[0].level)
+	assert.Equal(t, "user", c.entries[0].fields["collection"])
+	assert.Equal(t, "boom", c.entries[0].fields["error"])
+	assert.NotContains(t, c.entries[0].fields, "filter")
+
+	// 输出文档时按规则脱敏
+	SetLogOptions(LogOptions{Level: LevelDebug, RedactRules: []RedactRule{RedactKeys("email")}})
+	rec, _ = startOp(context.Background(), "user", "FindOne", bson.M{"email": "a@b.c"}, nil)
+	rec.Result = bson.M{"email": "a@b.c", "name": "Alice"}
+	rec.finish(nil)
+	assert.Len(t, c.entries, 2)
+	assert.Equal(t, bson.M{"email": RedactedValue}, c.entries[1].fields["filter"])
+	assert.Equal(t, bson.M{"email": RedactedValue, "name": "Alice"}, c.entries[1].fields["result"])
+}
+
+func TestConvertErrorLog(t *testing.T) {
+	c := &captureLogger{}
+	SetLogger(c)
+	defer SetLogger(nil)
+	defer SetLogOptions(DefaultLogOptions())
+
+	// 文档转换失败经过 Logger 输出，带有集合名
+	_, err := InsertOneWithCreateTime("user", 1)
+	assert.Error(t, err)
+	if assert.Len(t, c.entries, 1) {
+		assert.Equal(t, LevelError, c.entries[0].level)
+		assert.Equal(t, "user", c.entries[0].fields["collection"])
+	}
+
+	// LevelOff 时不输出
+	SetLogLevel(LevelOff)
+	_, err = UpdateWithUpdateTime("user", string(KindUpdateOne), bson.M{}, 1)
+	assert.Error(t, err)
+	assert.Len(t, c.entries, 1)
+}
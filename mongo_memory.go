@@ -0,0 +1,503 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MemoryBackend 保存在内存中的 Backend，用于不依赖数据库的单元测试。
+// 支持常用的查询操作符、更新操作符、排序、分页、投影和 upsert，
+// 不支持的操作符返回错误而不是静默忽略；只有 _id 是唯一的。
+// ctx 经 WithDatabase 指定了数据库时，集合名为 <数据库>.<集合>
+type MemoryBackend struct {
+	mu          sync.Mutex
+	collections map[string][]bson.M
+}
+
+// NewMemoryBackend 创建空的 MemoryBackend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{collections: map[string][]bson.M{}}
+}
+
+// Seed 直接写入文档，不经过中间件和钩子，缺少 _id 时自动生成
+func (m *MemoryBackend) Seed(collectionName string, docs ...interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range docs {
+		doc, err := normalizeDoc(d)
+		if err != nil {
+			return err
+		}
+		if err := m.insert(collectionName, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Documents 返回集合中全部文档的副本，按插入顺序
+func (m *MemoryBackend) Documents(collectionName string) []bson.M {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	docs := m.collections[collectionName]
+	out := make([]bson.M, 0, len(docs))
+	for _, d := range docs {
+		out = append(out, cloneDoc(d))
+	}
+	return out
+}
+
+// Collections 返回有数据的集合名，按名称排序
+func (m *MemoryBackend) Collections() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.collections))
+	for name := range m.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Drop 删除集合
+func (m *MemoryBackend) Drop(collectionName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.collections, collectionName)
+}
+
+// Reset 清空全部集合
+func (m *MemoryBackend) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.collections = map[string][]bson.M{}
+}
+
+func (m *MemoryBackend) FindOne(ctx context.Context, op *Operation) (bson.M, error) {
+	opts, err := convertOpts[*options.FindOneOptions](op.Kind, op.Options)
+	if err != nil {
+		return nil, err
+	}
+	var sortBy, projection interface{}
+	var skip int64
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		if o.Sort != nil {
+			sortBy = o.Sort
+		}
+		if o.Projection != nil {
+			projection = o.Projection
+		}
+		if o.Skip != nil {
+			skip = *o.Skip
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	docs, err := m.query(namespace(ctx, op.Collection), op.Filter, sortBy, skip, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+	return project(cloneDoc(docs[0]), projection)
+}
+
+func (m *MemoryBackend) FindList(ctx context.Context, op *Operation) ([]bson.M, error) {
+	opts, err := convertOpts[*options.FindOptions](op.Kind, op.Options)
+	if err != nil {
+		return nil, err
+	}
+	var sortBy, projection interface{}
+	var skip, limit int64
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		if o.Sort != nil {
+			sortBy = o.Sort
+		}
+		if o.Projection != nil {
+			projection = o.Projection
+		}
+		if o.Skip != nil {
+			skip = *o.Skip
+		}
+		if o.Limit != nil {
+			limit = *o.Limit
+		}
+	}
+	if limit < 0 {
+		limit = -limit
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	docs, err := m.query(namespace(ctx, op.Collection), op.Filter, sortBy, skip, limit)
+	if err != nil {
+		return nil, err
+	}
+	var results []bson.M
+	for _, d := range docs {
+		p, err := project(cloneDoc(d), projection)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, p)
+	}
+	return results, nil
+}
+
+func (m *MemoryBackend) Count(ctx context.Context, op *Operation) (int64, error) {
+	opts, err := convertOpts[*options.CountOptions](op.Kind, op.Options)
+	if err != nil {
+		return 0, err
+	}
+	var skip, limit int64
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		if o.Skip != nil {
+			skip = *o.Skip
+		}
+		if o.Limit != nil {
+			limit = *o.Limit
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	docs, err := m.query(namespace(ctx, op.Collection), op.Filter, nil, skip, limit)
+	return int64(len(docs)), err
+}
+
+func (m *MemoryBackend) InsertOne(ctx context.Context, op *Operation) (*mongo.InsertOneResult, error) {
+	if _, err := convertOpts[*options.InsertOneOptions](op.Kind, op.Options); err != nil {
+		return nil, err
+	}
+	doc, err := normalizeDoc(op.Document)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.insert(namespace(ctx, op.Collection), doc); err != nil {
+		return nil, err
+	}
+	return &mongo.InsertOneResult{InsertedID: doc["_id"]}, nil
+}
+
+func (m *MemoryBackend) Update(ctx context.Context, op *Operation) (*mongo.UpdateResult, error) {
+	var upsert bool
+	if op.Kind == KindReplaceOne {
+		opts, err := convertOpts[*options.ReplaceOptions](op.Kind, op.Options)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range opts {
+			if o != nil && o.Upsert != nil {
+				upsert = *o.Upsert
+			}
+		}
+	} else {
+		opts, err := convertOpts[*options.UpdateOptions](KindUpdateOne, op.Options)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range opts {
+			if o != nil && o.Upsert != nil {
+				upsert = *o.Upsert
+			}
+		}
+	}
+	switch op.Kind {
+	case KindUpdateOne, KindUpdateMany, KindSoftDelete, KindReplaceOne:
+	default:
+		return nil, fmt.Errorf("updateType 参数错误")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	limit := int64(1)
+	if op.Kind == KindUpdateMany {
+		limit = 0
+	}
+	docs, err := m.query(namespace(ctx, op.Collection), op.Filter, nil, 0, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &mongo.UpdateResult{MatchedCount: int64(len(docs))}
+	if len(docs) == 0 {
+		if !upsert {
+			return result, nil
+		}
+		doc, err := m.upsertDoc(op)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.insert(namespace(ctx, op.Collection), doc); err != nil {
+			return nil, err
+		}
+		result.UpsertedCount = 1
+		result.UpsertedID = doc["_id"]
+		return result, nil
+	}
+
+	for _, d := range docs {
+		changed, err := m.modify(d, op)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			result.ModifiedCount++
+		}
+	}
+	return result, nil
+}
+
+func (m *MemoryBackend) Delete(ctx context.Context, op *Operation) (*mongo.DeleteResult, error) {
+	if _, err := convertOpts[*options.DeleteOptions](op.Kind, op.Options); err != nil {
+		return nil, err
+	}
+	filter, err := normalizeDoc(op.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name := namespace(ctx, op.Collection)
+	docs := m.collections[name]
+	kept := docs[:0:0]
+	var deleted int64
+	for _, d := range docs {
+		if op.Kind == KindDeleteMany || deleted == 0 {
+			matched, err := matchDoc(d, filter)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				deleted++
+				continue
+			}
+		}
+		kept = append(kept, d)
+	}
+	m.collections[name] = kept
+	return &mongo.DeleteResult{DeletedCount: deleted}, nil
+}
+
+func (m *MemoryBackend) FindOneAndUpdate(ctx context.Context, op *Operation) (bson.M, error) {
+	opts, err := convertOpts[*options.FindOneAndUpdateOptions](op.Kind, op.Options)
+	if err != nil {
+		return nil, err
+	}
+	var sortBy, projection interface{}
+	var upsert bool
+	returnDoc := options.Before
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		if o.Sort != nil {
+			sortBy = o.Sort
+		}
+		if o.Projection != nil {
+			projection = o.Projection
+		}
+		if o.Upsert != nil {
+			upsert = *o.Upsert
+		}
+		if o.ReturnDocument != nil {
+			returnDoc = *o.ReturnDocument
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	docs, err := m.query(namespace(ctx, op.Collection), op.Filter, sortBy, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(docs) == 0 {
+		if !upsert {
+			return nil, mongo.ErrNoDocuments
+		}
+		doc, err := m.upsertDoc(op)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.insert(namespace(ctx, op.Collection), doc); err != nil {
+			return nil, err
+		}
+		if returnDoc == options.Before {
+			return nil, mongo.ErrNoDocuments
+		}
+		return project(cloneDoc(doc), projection)
+	}
+
+	before := cloneDoc(docs[0])
+	if _, err := m.modify(docs[0], op); err != nil {
+		return nil, err
+	}
+	if returnDoc == options.Before {
+		return project(before, projection)
+	}
+	return project(cloneDoc(docs[0]), projection)
+}
+
+// query 返回匹配的文档（未复制），调用方需持有锁
+func (m *MemoryBackend) query(collectionName string, filter, sortBy interface{}, skip, limit int64) ([]bson.M, error) {
+	f, err := normalizeDoc(filter)
+	if err != nil {
+		return nil, err
+	}
+	var docs []bson.M
+	for _, d := range m.collections[collectionName] {
+		matched, err := matchDoc(d, f)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			docs = append(docs, d)
+		}
+	}
+	if err := sortDocs(docs, sortBy); err != nil {
+		return nil, err
+	}
+	if skip > 0 {
+		if skip >= int64(len(docs)) {
+			return nil, nil
+		}
+		docs = docs[skip:]
+	}
+	if limit > 0 && limit < int64(len(docs)) {
+		docs = docs[:limit]
+	}
+	return docs, nil
+}
+
+// insert 写入文档，缺少 _id 时生成 ObjectID，_id 重复时返回与驱动一致的 duplicate key 错误
+func (m *MemoryBackend) insert(collectionName string, doc bson.M) error {
+	if _, ok := doc["_id"]; !ok {
+		doc["_id"] = primitive.NewObjectID()
+	}
+	for _, d := range m.collections[collectionName] {
+		if valuesEqual(d["_id"], doc["_id"]) {
+			return mongo.WriteException{WriteErrors: []mongo.WriteError{{
+				Code:    11000,
+				Message: fmt.Sprintf("E11000 duplicate key error collection: %s dup key: { _id: %v }", collectionName, doc["_id"]),
+			}}}
+		}
+	}
+	m.collections[collectionName] = append(m.collections[collectionName], doc)
+	return nil
+}
+
+// updateDoc 按驱动的方式组装更新文档：Document 放在 $set 中，再追加 Operators
+func updateDoc(op *Operation) (bson.M, error) {
+	update := bson.D{}
+	if op.Document != nil {
+		update = append(update, bson.E{Key: "$set", Value: op.Document})
+	}
+	update = append(update, op.Operators...)
+	if len(update) == 0 {
+		return nil, fmt.Errorf("更新文档不能为空")
+	}
+	return normalizeDoc(update)
+}
+
+// modify 在原文档上执行更新或替换，返回文档是否发生变化
+func (m *MemoryBackend) modify(doc bson.M, op *Operation) (bool, error) {
+	next := cloneDoc(doc)
+	if op.Kind == KindReplaceOne {
+		replacement, err := normalizeDoc(op.Document)
+		if err != nil {
+			return false, err
+		}
+		if id, ok := replacement["_id"]; ok && !valuesEqual(id, doc["_id"]) {
+			return false, fmt.Errorf("不能修改 _id 字段")
+		}
+		replacement["_id"] = doc["_id"]
+		next = replacement
+	} else {
+		update, err := updateDoc(op)
+		if err != nil {
+			return false, err
+		}
+		if err := applyUpdate(next, update, false); err != nil {
+			return false, err
+		}
+	}
+	if compareDocs(doc, next) == 0 {
+		return false, nil
+	}
+	for k := range doc {
+		delete(doc, k)
+	}
+	for k, v := range next {
+		doc[k] = v
+	}
+	return true, nil
+}
+
+// upsertDoc 生成 upsert 插入的文档：过滤条件中的相等字段加上更新内容
+func (m *MemoryBackend) upsertDoc(op *Operation) (bson.M, error) {
+	filter, err := normalizeDoc(op.Filter)
+	if err != nil {
+		return nil, err
+	}
+	seed := upsertSeed(filter)
+	if op.Kind == KindReplaceOne {
+		replacement, err := normalizeDoc(op.Document)
+		if err != nil {
+			return nil, err
+		}
+		if id, ok := seed["_id"]; ok {
+			if _, has := replacement["_id"]; !has {
+				replacement["_id"] = id
+			}
+		}
+		return replacement, nil
+	}
+	update, err := updateDoc(op)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyUpdate(seed, update, true); err != nil {
+		return nil, err
+	}
+	return cloneDoc(seed), nil
+}
+
+// namespace ctx 中指定了数据库时在集合名前加上数据库名
+func namespace(ctx context.Context, collectionName string) string {
+	if db := DatabaseFromContext(ctx); db != "" {
+		return db + "." + collectionName
+	}
+	return collectionName
+}
+
+func cloneDoc(doc bson.M) bson.M {
+	c, err := normalizeDoc(doc)
+	if err != nil {
+		// 文档都经过 normalizeDoc，不会出错
+		panic(err)
+	}
+	return c
+}
@@ -0,0 +1,630 @@
+package mongodb
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 内存后端的查询实现：过滤条件匹配、比较、排序和投影
+
+// normalizeDoc 通过一次 BSON 编解码把任意文档转为 bson.M，
+// 类型与驱动从数据库读出的一致（嵌套文档为 bson.M，数组为 bson.A，时间为 primitive.DateTime）
+func normalizeDoc(v interface{}) (bson.M, error) {
+	if v == nil {
+		return bson.M{}, nil
+	}
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m bson.M
+	if err := bson.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// normalizeValue 规范化单个值
+func normalizeValue(v interface{}) (interface{}, error) {
+	m, err := normalizeDoc(bson.M{"v": v})
+	if err != nil {
+		return nil, err
+	}
+	return m["v"], nil
+}
+
+// matchDoc 判断文档是否满足过滤条件
+func matchDoc(doc bson.M, filter bson.M) (bool, error) {
+	for key, cond := range filter {
+		ok, err := matchClause(doc, key, cond)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchClause(doc bson.M, key string, cond interface{}) (bool, error) {
+	switch key {
+	case "$and", "$or", "$nor":
+		subs, ok := cond.(bson.A)
+		if !ok || len(subs) == 0 {
+			return false, fmt.Errorf("%s 需要非空数组", key)
+		}
+		for _, sub := range subs {
+			sm, ok := sub.(bson.M)
+			if !ok {
+				return false, fmt.Errorf("%s 的元素必须是文档", key)
+			}
+			matched, err := matchDoc(doc, sm)
+			if err != nil {
+				return false, err
+			}
+			switch {
+			case key == "$and" && !matched:
+				return false, nil
+			case key == "$or" && matched:
+				return true, nil
+			case key == "$nor" && matched:
+				return false, nil
+			}
+		}
+		return key != "$or", nil
+	case "$comment":
+		return true, nil
+	}
+	if strings.HasPrefix(key, "$") {
+		return false, fmt.Errorf("内存后端不支持顶层操作符 %s", key)
+	}
+	values, exists := lookupPath(doc, key)
+	return matchValues(values, exists, cond)
+}
+
+// isOperatorDoc 文档的键是否都是 $ 开头的操作符
+func isOperatorDoc(v interface{}) (bson.M, bool) {
+	m, ok := v.(bson.M)
+	if !ok || len(m) == 0 {
+		return nil, false
+	}
+	for k := range m {
+		if !strings.HasPrefix(k, "$") {
+			return nil, false
+		}
+	}
+	return m, true
+}
+
+// matchValues 判断路径上的值是否满足条件，cond 为操作符文档或要相等的值
+func matchValues(values []interface{}, exists bool, cond interface{}) (bool, error) {
+	ops, isOps := isOperatorDoc(cond)
+	if !isOps {
+		return matchEq(values, exists, cond), nil
+	}
+	for op, arg := range ops {
+		ok, err := matchOperator(values, exists, op, arg, ops)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchOperator(values []interface{}, exists bool, op string, arg interface{}, ops bson.M) (bool, error) {
+	switch op {
+	case "$eq":
+		return matchEq(values, exists, arg), nil
+	case "$ne":
+		return !matchEq(values, exists, arg), nil
+	case "$gt", "$gte", "$lt", "$lte":
+		return anyCandidate(values, func(v interface{}) bool {
+			c, ok := compareValues(v, arg)
+			if !ok {
+				return false
+			}
+			switch op {
+			case "$gt":
+				return c > 0
+			case "$gte":
+				return c >= 0
+			case "$lt":
+				return c < 0
+			default:
+				return c <= 0
+			}
+		}), nil
+	case "$in", "$nin":
+		list, ok := arg.(bson.A)
+		if !ok {
+			return false, fmt.Errorf("%s 需要数组", op)
+		}
+		in := false
+		for _, item := range list {
+			if re, ok := item.(primitive.Regex); ok {
+				if matchRegex(values, re.Pattern, re.Options) {
+					in = true
+					break
+				}
+				continue
+			}
+			if matchEq(values, exists, item) {
+				in = true
+				break
+			}
+		}
+		if op == "$in" {
+			return in, nil
+		}
+		return !in, nil
+	case "$exists":
+		return exists == truthy(arg), nil
+	case "$size":
+		n := toInt64(arg)
+		for _, v := range values {
+			if a, ok := v.(bson.A); ok && int64(len(a)) == n {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$all":
+		list, ok := arg.(bson.A)
+		if !ok {
+			return false, fmt.Errorf("$all 需要数组")
+		}
+		for _, item := range list {
+			if !matchEq(values, exists, item) {
+				return false, nil
+			}
+		}
+		return len(list) > 0, nil
+	case "$elemMatch":
+		sub, ok := arg.(bson.M)
+		if !ok {
+			return false, fmt.Errorf("$elemMatch 需要文档")
+		}
+		for _, v := range values {
+			arr, ok := v.(bson.A)
+			if !ok {
+				continue
+			}
+			for _, el := range arr {
+				var matched bool
+				var err error
+				if _, isOps := isOperatorDoc(sub); isOps {
+					matched, err = matchValues([]interface{}{el}, true, sub)
+				} else if elDoc, ok := el.(bson.M); ok {
+					matched, err = matchDoc(elDoc, sub)
+				}
+				if err != nil {
+					return false, err
+				}
+				if matched {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	case "$not":
+		matched, err := matchValues(values, exists, arg)
+		return !matched, err
+	case "$regex":
+		pattern, opts := "", ""
+		switch r := arg.(type) {
+		case string:
+			pattern = r
+		case primitive.Regex:
+			pattern, opts = r.Pattern, r.Options
+		default:
+			return false, fmt.Errorf("$regex 需要字符串")
+		}
+		if o, ok := ops["$options"].(string); ok {
+			opts = o
+		}
+		return matchRegex(values, pattern, opts), nil
+	case "$options":
+		return true, nil
+	}
+	return false, fmt.Errorf("内存后端不支持操作符 %s", op)
+}
+
+// anyCandidate 值本身或数组中的任一元素满足条件
+func anyCandidate(values []interface{}, fn func(v interface{}) bool) bool {
+	for _, v := range values {
+		if fn(v) {
+			return true
+		}
+		if arr, ok := v.(bson.A); ok {
+			for _, el := range arr {
+				if fn(el) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func matchEq(values []interface{}, exists bool, target interface{}) bool {
+	if re, ok := target.(primitive.Regex); ok {
+		return matchRegex(values, re.Pattern, re.Options)
+	}
+	if target == nil && !exists {
+		return true
+	}
+	for _, v := range values {
+		if valuesEqual(v, target) {
+			return true
+		}
+	}
+	// 目标不是数组时，数组字段中任一元素相等即可
+	if _, isArr := target.(bson.A); !isArr {
+		return anyCandidate(values, func(v interface{}) bool { return valuesEqual(v, target) })
+	}
+	return false
+}
+
+func matchRegex(values []interface{}, pattern, opts string) bool {
+	flags := ""
+	for _, o := range opts {
+		switch o {
+		case 'i', 'm', 's':
+			flags += string(o)
+		}
+	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return false
+	}
+	return anyCandidate(values, func(v interface{}) bool {
+		s, ok := v.(string)
+		return ok && re.MatchString(s)
+	})
+}
+
+func truthy(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case nil:
+		return false
+	}
+	return toFloat64(v) != 0
+}
+
+// lookupPath 按点路径取值，路径经过数组时展开数组中的每个文档
+func lookupPath(doc bson.M, path string) ([]interface{}, bool) {
+	return lookupParts(doc, strings.Split(path, "."))
+}
+
+func lookupParts(cur interface{}, parts []string) ([]interface{}, bool) {
+	if len(parts) == 0 {
+		return []interface{}{cur}, true
+	}
+	switch v := cur.(type) {
+	case bson.M:
+		next, ok := v[parts[0]]
+		if !ok {
+			return nil, false
+		}
+		return lookupParts(next, parts[1:])
+	case bson.A:
+		if idx, err := strconv.Atoi(parts[0]); err == nil {
+			if idx < 0 || idx >= len(v) {
+				return nil, false
+			}
+			return lookupParts(v[idx], parts[1:])
+		}
+		var out []interface{}
+		found := false
+		for _, el := range v {
+			if vals, ok := lookupParts(el, parts); ok {
+				out = append(out, vals...)
+				found = true
+			}
+		}
+		return out, found
+	}
+	return nil, false
+}
+
+// typeOrder BSON 的类型排序
+func typeOrder(v interface{}) int {
+	switch v.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return 1
+	case int, int32, int64, float32, float64, primitive.Decimal128:
+		return 2
+	case string, primitive.Symbol:
+		return 3
+	case bson.M, bson.D:
+		return 4
+	case bson.A:
+		return 5
+	case primitive.Binary, []byte:
+		return 6
+	case primitive.ObjectID:
+		return 7
+	case bool:
+		return 8
+	case primitive.DateTime, time.Time:
+		return 9
+	case primitive.Timestamp:
+		return 10
+	case primitive.Regex:
+		return 11
+	}
+	return 12
+}
+
+// compareValues 比较两个值，类型不同时 ok 为 false（与 $gt 等操作符的行为一致）
+func compareValues(a, b interface{}) (int, bool) {
+	ta, tb := typeOrder(a), typeOrder(b)
+	if ta != tb {
+		return 0, false
+	}
+	switch ta {
+	case 1:
+		return 0, true
+	case 2:
+		fa, fb := numberValue(a), numberValue(b)
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	case 3:
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b)), true
+	case 4:
+		da, _ := normalizeDoc(a)
+		db, _ := normalizeDoc(b)
+		return compareDocs(da, db), true
+	case 5:
+		aa, ab := a.(bson.A), b.(bson.A)
+		for i := 0; i < len(aa) && i < len(ab); i++ {
+			if c := sortCompare(aa[i], ab[i]); c != 0 {
+				return c, true
+			}
+		}
+		return len(aa) - len(ab), true
+	case 6:
+		return bytes.Compare(binaryData(a), binaryData(b)), true
+	case 7:
+		oa, ob := a.(primitive.ObjectID), b.(primitive.ObjectID)
+		return bytes.Compare(oa[:], ob[:]), true
+	case 8:
+		ba, bb := a.(bool), b.(bool)
+		switch {
+		case ba == bb:
+			return 0, true
+		case !ba:
+			return -1, true
+		}
+		return 1, true
+	case 9:
+		ma, mb := dateMillis(a), dateMillis(b)
+		switch {
+		case ma < mb:
+			return -1, true
+		case ma > mb:
+			return 1, true
+		}
+		return 0, true
+	case 10:
+		return primitive.CompareTimestamp(a.(primitive.Timestamp), b.(primitive.Timestamp)), true
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b)), true
+}
+
+// sortCompare 排序时的比较，类型不同时按 BSON 类型顺序
+func sortCompare(a, b interface{}) int {
+	if c, ok := compareValues(a, b); ok {
+		return c
+	}
+	return typeOrder(a) - typeOrder(b)
+}
+
+func compareDocs(a, b bson.M) int {
+	ka, kb := sortByMapKeys(a), sortByMapKeys(b)
+	for i := 0; i < len(ka) && i < len(kb); i++ {
+		if c := strings.Compare(ka[i], kb[i]); c != 0 {
+			return c
+		}
+		if c := sortCompare(a[ka[i]], b[kb[i]]); c != 0 {
+			return c
+		}
+	}
+	return len(ka) - len(kb)
+}
+
+func valuesEqual(a, b interface{}) bool {
+	if ab, ok := a.(bson.A); ok {
+		bb, ok := b.(bson.A)
+		if !ok || len(ab) != len(bb) {
+			return false
+		}
+		for i := range ab {
+			if !valuesEqual(ab[i], bb[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	c, ok := compareValues(a, b)
+	return ok && c == 0
+}
+
+func numberValue(v interface{}) float64 {
+	if d, ok := v.(primitive.Decimal128); ok {
+		f, err := strconv.ParseFloat(d.String(), 64)
+		if err != nil {
+			return math.NaN()
+		}
+		return f
+	}
+	return toFloat64(v)
+}
+
+func binaryData(v interface{}) []byte {
+	switch b := v.(type) {
+	case primitive.Binary:
+		return b.Data
+	case []byte:
+		return b
+	}
+	return nil
+}
+
+func dateMillis(v interface{}) int64 {
+	switch d := v.(type) {
+	case primitive.DateTime:
+		return int64(d)
+	case time.Time:
+		return d.UnixMilli()
+	}
+	return 0
+}
+
+// sortSpec 将 bson.D 或 bson.M 形式的排序转为有序列表，bson.M 按键名排序
+func sortSpec(s interface{}) (bson.D, error) {
+	switch v := s.(type) {
+	case nil:
+		return nil, nil
+	case bson.D:
+		return v, nil
+	case bson.M:
+		return MapToBsonD(v)
+	case map[string]interface{}:
+		return MapToBsonD(v)
+	}
+	return nil, fmt.Errorf("内存后端不支持的排序类型 %T", s)
+}
+
+func sortDocs(docs []bson.M, s interface{}) error {
+	spec, err := sortSpec(s)
+	if err != nil || len(spec) == 0 {
+		return err
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		for _, e := range spec {
+			vi, _ := lookupPath(docs[i], e.Key)
+			vj, _ := lookupPath(docs[j], e.Key)
+			c := sortCompare(firstValue(vi), firstValue(vj))
+			if c == 0 {
+				continue
+			}
+			if toInt64(e.Value) < 0 {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+	return nil
+}
+
+func firstValue(values []interface{}) interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// project 按投影返回新文档，支持包含和排除两种模式
+func project(doc bson.M, projection interface{}) (bson.M, error) {
+	if projection == nil {
+		return doc, nil
+	}
+	spec, err := normalizeDoc(projection)
+	if err != nil {
+		return nil, err
+	}
+	if len(spec) == 0 {
+		return doc, nil
+	}
+
+	include := false
+	for k, v := range spec {
+		if _, isOps := isOperatorDoc(v); isOps {
+			return nil, fmt.Errorf("内存后端不支持投影操作符 %s", k)
+		}
+		// 只有 {_id: 1} 时同样是包含模式
+		if truthy(v) {
+			include = true
+		}
+	}
+
+	if !include {
+		out, _ := normalizeDoc(doc)
+		for k := range spec {
+			unsetPath(out, k)
+		}
+		return out, nil
+	}
+
+	out := bson.M{}
+	if v, ok := spec["_id"]; !ok || truthy(v) {
+		if id, ok := doc["_id"]; ok {
+			out["_id"] = id
+		}
+	}
+	for k, v := range spec {
+		if k == "_id" || !truthy(v) {
+			continue
+		}
+		if values, ok := lookupPath(doc, k); ok && !strings.Contains(k, ".") {
+			out[k] = values[0]
+		} else if ok {
+			copyPath(doc, out, strings.Split(k, "."))
+		}
+	}
+	return normalizeDoc(out)
+}
+
+// copyPath 把 src 中的点路径复制到 dst，保留嵌套结构
+func copyPath(src, dst bson.M, parts []string) {
+	v, ok := src[parts[0]]
+	if !ok {
+		return
+	}
+	if len(parts) == 1 {
+		dst[parts[0]] = v
+		return
+	}
+	switch sv := v.(type) {
+	case bson.M:
+		sub, _ := dst[parts[0]].(bson.M)
+		if sub == nil {
+			sub = bson.M{}
+			dst[parts[0]] = sub
+		}
+		copyPath(sv, sub, parts[1:])
+	case bson.A:
+		arr, _ := dst[parts[0]].(bson.A)
+		if arr == nil {
+			arr = make(bson.A, len(sv))
+			for i := range arr {
+				arr[i] = bson.M{}
+			}
+		}
+		for i, el := range sv {
+			if em, ok := el.(bson.M); ok {
+				sub, _ := arr[i].(bson.M)
+				copyPath(em, sub, parts[1:])
+			}
+		}
+		dst[parts[0]] = arr
+	}
+}
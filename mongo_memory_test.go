@@ -0,0 +1,129 @@
+package mongodb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func useTestMemoryBackend(t *testing.T) *MemoryBackend {
+	prev := GetBackend()
+	t.Cleanup(func() { SetBackend(prev) })
+	return UseMemoryBackend()
+}
+
+func TestMemoryBackendFind(t *testing.T) {
+	m := useTestMemoryBackend(t)
+	assert.NoError(t, m.Seed("user",
+		bson.M{"_id": 1, "name": "Alice", "age": 30, "tags": bson.A{"admin", "dev"}, "addr": bson.M{"city": "Beijing"}},
+		bson.M{"_id": 2, "name": "Bob", "age": 25, "tags": bson.A{"dev"}, "addr": bson.M{"city": "Shanghai"}},
+		bson.M{"_id": 3, "name": "carol", "age": 35},
+	))
+
+	cases := []struct {
+		filter interface{}
+		ids    []interface{}
+	}{
+		{bson.M{}, []interface{}{int32(1), int32(2), int32(3)}},
+		{bson.M{"age": bson.M{"$gte": 30}}, []interface{}{int32(1), int32(3)}},
+		{bson.M{"tags": "dev"}, []interface{}{int32(1), int32(2)}},
+		{bson.M{"tags": bson.M{"$all": bson.A{"admin", "dev"}}}, []interface{}{int32(1)}},
+		{bson.M{"addr.city": "Shanghai"}, []interface{}{int32(2)}},
+		{bson.M{"addr": bson.M{"$exists": false}}, []interface{}{int32(3)}},
+		{bson.M{"name": bson.M{"$regex": "^c", "$options": "i"}}, []interface{}{int32(3)}},
+		{bson.M{"$or": bson.A{bson.M{"_id": 1}, bson.M{"age": bson.M{"$lt": 30}}}}, []interface{}{int32(1), int32(2)}},
+		{bson.D{{Key: "_id", Value: bson.M{"$nin": bson.A{1, 2}}}}, []interface{}{int32(3)}},
+		{bson.M{"age": bson.M{"$not": bson.M{"$gt": 26}}}, []interface{}{int32(2)}},
+	}
+	for _, c := range cases {
+		docs, err := FindList("user", c.filter)
+		assert.NoError(t, err, c.filter)
+		var ids []interface{}
+		for _, d := range docs {
+			ids = append(ids, d["_id"])
+		}
+		assert.Equal(t, c.ids, ids, c.filter)
+	}
+
+	docs, err := FindList("user", bson.M{}, options.Find().
+		SetSort(bson.D{{Key: "age", Value: -1}}).SetSkip(1).SetLimit(1).
+		SetProjection(bson.M{"name": 1, "_id": 0}))
+	assert.NoError(t, err)
+	assert.Equal(t, []bson.M{{"name": "Alice"}}, docs)
+	doc, err := FindOne("user", bson.M{"name": "Bob"}, options.FindOne().SetProjection(bson.M{"_id": 1}))
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"_id": int32(2)}, doc)
+
+	_, err = FindOne("user", bson.M{"_id": 9})
+	assert.ErrorIs(t, err, mongo.ErrNoDocuments)
+
+	n, err := Count("user", bson.M{"tags": bson.M{"$size": 1}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	_, err = FindList("user", bson.M{"$where": "true"})
+	assert.Error(t, err)
+}
+
+func TestMemoryBackendWrite(t *testing.T) {
+	m := useTestMemoryBackend(t)
+	ctx := context.Background()
+
+	res, err := InsertOne("user", bson.M{"name": "Alice", "score": 1, "tags": bson.A{"a"}})
+	assert.NoError(t, err)
+	id := res.InsertedID
+
+	_, err = InsertOne("user", bson.M{"_id": id})
+	assert.True(t, mongo.IsDuplicateKeyError(err))
+
+	_, err = FindOneAndUpdateWithContext(ctx, "user", bson.M{"_id": id}, bson.M{"name": "Alice2"}, bson.D{
+		{Key: "$inc", Value: bson.M{"score": 2}},
+		{Key: "$push", Value: bson.M{"tags": bson.M{"$each": bson.A{"b", "c"}}}},
+		{Key: "$unset", Value: bson.M{"missing": ""}},
+	})
+	assert.NoError(t, err)
+	doc, err := FindOne("user", bson.M{"_id": id})
+	assert.NoError(t, err)
+	assert.Equal(t, "Alice2", doc["name"])
+	assert.Equal(t, int32(3), doc["score"])
+	assert.Equal(t, bson.A{"a", "b", "c"}, doc["tags"])
+
+	ur, err := Update("user", string(KindUpdateOne), bson.M{"_id": id}, bson.M{"name": "Alice2"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), ur.MatchedCount)
+	assert.Equal(t, int64(0), ur.ModifiedCount)
+
+	up, err := UpsertWithContext(ctx, "user", bson.M{"email": "b@b.c"}, bson.M{"name": "Bob"}, bson.M{"status": "new"})
+	assert.NoError(t, err)
+	assert.True(t, up.Inserted)
+	bob, err := FindOne("user", bson.M{"email": "b@b.c"})
+	assert.NoError(t, err)
+	assert.Equal(t, "new", bob["status"])
+	assert.NotEmpty(t, bob["create_time"])
+
+	// $setOnInsert 只在插入时生效
+	_, err = UpsertWithContext(ctx, "user", bson.M{"email": "b@b.c"}, bson.M{"name": "Bob2"}, bson.M{"status": "again"})
+	assert.NoError(t, err)
+	bob, _ = FindOne("user", bson.M{"email": "b@b.c"})
+	assert.Equal(t, "new", bob["status"])
+	assert.Equal(t, "Bob2", bob["name"])
+
+	// 同一路径或互为前缀的路径出现在多个更新中时与 MongoDB 一样报错
+	for _, ops := range []bson.D{
+		{{Key: "$setOnInsert", Value: bson.M{"name": "x"}}},
+		{{Key: "$unset", Value: bson.M{"name.first": ""}}},
+		{{Key: "$rename", Value: bson.M{"email": "name"}}},
+	} {
+		_, err = FindOneAndUpdateWithContext(ctx, "user", bson.M{"_id": id}, bson.M{"name": "x"}, ops)
+		assert.Error(t, err, ops)
+	}
+
+	dr, err := Delete("user", string(KindDeleteMany), bson.M{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), dr.DeletedCount)
+	assert.Empty(t, m.Documents("user"))
+}
@@ -0,0 +1,384 @@
+package mongodb
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 内存后端的更新实现：更新操作符和点路径读写
+
+// setPath 按点路径写入值，中间缺少的文档会自动创建，数组可以用数字下标
+func setPath(doc bson.M, path string, value interface{}) error {
+	parts := strings.Split(path, ".")
+	var cur interface{} = doc
+	for i, part := range parts {
+		last := i == len(parts)-1
+		switch c := cur.(type) {
+		case bson.M:
+			if last {
+				c[part] = value
+				return nil
+			}
+			next, ok := c[part]
+			if !ok || next == nil {
+				next = bson.M{}
+				c[part] = next
+			}
+			cur = next
+		case bson.A:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 {
+				return fmt.Errorf("无法在数组上设置字段 %s", path)
+			}
+			if idx >= len(c) {
+				return fmt.Errorf("数组下标越界 %s", path)
+			}
+			if last {
+				c[idx] = value
+				return nil
+			}
+			if c[idx] == nil {
+				c[idx] = bson.M{}
+			}
+			cur = c[idx]
+		default:
+			return fmt.Errorf("无法在 %T 上设置字段 %s", cur, path)
+		}
+	}
+	return nil
+}
+
+// getPath 按点路径取单个值，不展开数组
+func getPath(doc bson.M, path string) (interface{}, bool) {
+	var cur interface{} = doc
+	for _, part := range strings.Split(path, ".") {
+		switch c := cur.(type) {
+		case bson.M:
+			v, ok := c[part]
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case bson.A:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(c) {
+				return nil, false
+			}
+			cur = c[idx]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// unsetPath 删除点路径上的字段，数组元素置为 null
+func unsetPath(doc bson.M, path string) {
+	parts := strings.Split(path, ".")
+	parent, ok := getPath(doc, strings.Join(parts[:len(parts)-1], "."))
+	if len(parts) == 1 {
+		parent, ok = doc, true
+	}
+	if !ok {
+		return
+	}
+	last := parts[len(parts)-1]
+	switch p := parent.(type) {
+	case bson.M:
+		delete(p, last)
+	case bson.A:
+		if idx, err := strconv.Atoi(last); err == nil && idx >= 0 && idx < len(p) {
+			p[idx] = nil
+		}
+	}
+}
+
+// applyUpdate 对文档执行更新操作符，insert 为 true 时 $setOnInsert 生效
+func applyUpdate(doc bson.M, update bson.M, insert bool) error {
+	if err := checkUpdateConflicts(update); err != nil {
+		return err
+	}
+	for _, op := range sortByMapKeys(update) {
+		fields := update[op].(bson.M)
+		for _, path := range sortByMapKeys(fields) {
+			if path == "_id" && op != "$setOnInsert" && !insert {
+				if cur, ok := doc["_id"]; ok && (op != "$set" || !valuesEqual(cur, fields[path])) {
+					return fmt.Errorf("不能修改 _id 字段")
+				}
+			}
+			if err := applyOperator(doc, op, path, fields[path], insert); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkUpdateConflicts 与 MongoDB 一致，同一路径或互为前缀的路径不能出现在多个更新中，
+// 如 $set 和 $setOnInsert 同时修改 create_time
+func checkUpdateConflicts(update bson.M) error {
+	owners := map[string]string{}
+	var paths []string
+	for _, op := range sortByMapKeys(update) {
+		fields, ok := update[op].(bson.M)
+		if !ok {
+			return fmt.Errorf("%s 的参数必须是文档", op)
+		}
+		for _, path := range sortByMapKeys(fields) {
+			targets := []string{path}
+			if to, ok := fields[path].(string); ok && op == "$rename" {
+				targets = append(targets, to)
+			}
+			for _, t := range targets {
+				for _, p := range paths {
+					if t == p || strings.HasPrefix(t, p+".") || strings.HasPrefix(p, t+".") {
+						return fmt.Errorf("更新路径冲突: %s %s 与 %s %s", owners[p], p, op, t)
+					}
+				}
+				owners[t] = op
+				paths = append(paths, t)
+			}
+		}
+	}
+	return nil
+}
+
+func applyOperator(doc bson.M, op, path string, arg interface{}, insert bool) error {
+	cur, exists := getPath(doc, path)
+	switch op {
+	case "$set":
+		return setPath(doc, path, arg)
+	case "$setOnInsert":
+		if insert {
+			return setPath(doc, path, arg)
+		}
+		return nil
+	case "$unset":
+		unsetPath(doc, path)
+		return nil
+	case "$inc", "$mul":
+		if typeOrder(arg) != 2 {
+			return fmt.Errorf("%s 的参数必须是数字: %s", op, path)
+		}
+		if !exists {
+			if op == "$mul" {
+				return setPath(doc, path, multiplyNumbers(arg, int32(0)))
+			}
+			return setPath(doc, path, arg)
+		}
+		if typeOrder(cur) != 2 {
+			return fmt.Errorf("%s 不能作用于非数字字段: %s", op, path)
+		}
+		if op == "$mul" {
+			return setPath(doc, path, multiplyNumbers(cur, arg))
+		}
+		return setPath(doc, path, addNumbers(cur, arg))
+	case "$min", "$max":
+		if !exists {
+			return setPath(doc, path, arg)
+		}
+		c := sortCompare(arg, cur)
+		if (op == "$min" && c < 0) || (op == "$max" && c > 0) {
+			return setPath(doc, path, arg)
+		}
+		return nil
+	case "$currentDate":
+		if m, ok := arg.(bson.M); ok && m["$type"] == "timestamp" {
+			return setPath(doc, path, primitive.Timestamp{T: uint32(time.Now().Unix())})
+		}
+		return setPath(doc, path, primitive.NewDateTimeFromTime(time.Now()))
+	case "$rename":
+		to, ok := arg.(string)
+		if !ok {
+			return fmt.Errorf("$rename 的目标必须是字符串: %s", path)
+		}
+		if !exists {
+			return nil
+		}
+		unsetPath(doc, path)
+		return setPath(doc, to, cur)
+	case "$push", "$addToSet":
+		arr, err := arrayAt(cur, exists, op, path)
+		if err != nil {
+			return err
+		}
+		items := bson.A{arg}
+		position := -1
+		if m, ok := arg.(bson.M); ok {
+			if each, ok := m["$each"].(bson.A); ok {
+				items = each
+				if p, ok := m["$position"]; ok && op == "$push" {
+					position = int(toInt64(p))
+				}
+			}
+		}
+		var added bson.A
+		for _, item := range items {
+			if op == "$addToSet" && (containsValue(arr, item) || containsValue(added, item)) {
+				continue
+			}
+			added = append(added, item)
+		}
+		if position < 0 || position > len(arr) {
+			position = len(arr)
+		}
+		arr = append(arr[:position], append(added, arr[position:]...)...)
+		return setPath(doc, path, arr)
+	case "$pull":
+		if !exists {
+			return nil
+		}
+		arr, err := arrayAt(cur, exists, op, path)
+		if err != nil {
+			return err
+		}
+		out := bson.A{}
+		for _, el := range arr {
+			matched, err := matchPull(el, arg)
+			if err != nil {
+				return err
+			}
+			if !matched {
+				out = append(out, el)
+			}
+		}
+		return setPath(doc, path, out)
+	case "$pullAll":
+		if !exists {
+			return nil
+		}
+		arr, err := arrayAt(cur, exists, op, path)
+		if err != nil {
+			return err
+		}
+		list, _ := arg.(bson.A)
+		out := bson.A{}
+		for _, el := range arr {
+			if !containsValue(list, el) {
+				out = append(out, el)
+			}
+		}
+		return setPath(doc, path, out)
+	case "$pop":
+		if !exists {
+			return nil
+		}
+		arr, err := arrayAt(cur, exists, op, path)
+		if err != nil || len(arr) == 0 {
+			return err
+		}
+		if toInt64(arg) < 0 {
+			return setPath(doc, path, arr[1:])
+		}
+		return setPath(doc, path, arr[:len(arr)-1])
+	}
+	return fmt.Errorf("内存后端不支持更新操作符 %s", op)
+}
+
+func arrayAt(cur interface{}, exists bool, op, path string) (bson.A, error) {
+	if !exists || cur == nil {
+		return bson.A{}, nil
+	}
+	arr, ok := cur.(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("%s 不能作用于非数组字段: %s", op, path)
+	}
+	return append(bson.A{}, arr...), nil
+}
+
+func containsValue(arr bson.A, v interface{}) bool {
+	for _, el := range arr {
+		if valuesEqual(el, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPull $pull 的条件可以是值、操作符文档或对元素文档的过滤条件
+func matchPull(el, cond interface{}) (bool, error) {
+	if _, isOps := isOperatorDoc(cond); isOps {
+		return matchValues([]interface{}{el}, true, cond)
+	}
+	if m, ok := cond.(bson.M); ok {
+		if elDoc, ok := el.(bson.M); ok {
+			return matchDoc(elDoc, m)
+		}
+		return false, nil
+	}
+	return valuesEqual(el, cond), nil
+}
+
+// addNumbers 加法，结果类型与 MongoDB 一致：有浮点数时为 double，int32 溢出时提升为 int64
+func addNumbers(a, b interface{}) interface{} {
+	if isFloat(a) || isFloat(b) {
+		return numberValue(a) + numberValue(b)
+	}
+	sum := toInt64(a) + toInt64(b)
+	return narrowInt(a, b, sum)
+}
+
+func multiplyNumbers(a, b interface{}) interface{} {
+	if isFloat(a) || isFloat(b) {
+		return numberValue(a) * numberValue(b)
+	}
+	return narrowInt(a, b, toInt64(a)*toInt64(b))
+}
+
+func isFloat(v interface{}) bool {
+	switch v.(type) {
+	case float32, float64, primitive.Decimal128:
+		return true
+	}
+	return false
+}
+
+func narrowInt(a, b interface{}, n int64) interface{} {
+	_, a32 := a.(int32)
+	_, b32 := b.(int32)
+	if a32 && b32 && n >= math.MinInt32 && n <= math.MaxInt32 {
+		return int32(n)
+	}
+	return n
+}
+
+// upsertSeed 从过滤条件中取出相等条件，作为 upsert 插入文档的初始内容
+func upsertSeed(filter bson.M) bson.M {
+	seed := bson.M{}
+	collectSeed(filter, seed)
+	return seed
+}
+
+func collectSeed(filter bson.M, seed bson.M) {
+	for k, v := range filter {
+		if k == "$and" {
+			if subs, ok := v.(bson.A); ok {
+				for _, sub := range subs {
+					if sm, ok := sub.(bson.M); ok {
+						collectSeed(sm, seed)
+					}
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(k, "$") {
+			continue
+		}
+		if ops, isOps := isOperatorDoc(v); isOps {
+			if eq, ok := ops["$eq"]; ok {
+				_ = setPath(seed, k, eq)
+			}
+			continue
+		}
+		if _, isRegex := v.(primitive.Regex); isRegex {
+			continue
+		}
+		_ = setPath(seed, k, v)
+	}
+}
@@ -0,0 +1,271 @@
+package mongodb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MetricsCollector 指标采集接口
+type MetricsCollector interface {
+	// ObserveOperation 记录一次操作，err 为 mongo.ErrNoDocuments 时不计为错误
+	ObserveOperation(collection, op string, duration time.Duration, err error)
+	// ObservePoolEvent 记录连接池事件
+	ObservePoolEvent(evt *event.PoolEvent)
+}
+
+var (
+	metricsMu sync.RWMutex
+	metrics   MetricsCollector
+)
+
+// SetMetricsCollector 设置指标采集器，传 nil 关闭指标
+func SetMetricsCollector(c MetricsCollector) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	metrics = c
+}
+
+// GetMetricsCollector 返回当前指标采集器，未设置时返回 nil
+func GetMetricsCollector() MetricsCollector {
+	metricsMu.RLock()
+	defer metricsMu.RUnlock()
+	return metrics
+}
+
+// PoolMonitor 返回把连接池事件转发给当前指标采集器的 PoolMonitor，
+// Connect 会自动设置，自行创建 client 时可以手动传入 options.Client().SetPoolMonitor
+func PoolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(evt *event.PoolEvent) {
+			if c := GetMetricsCollector(); c != nil {
+				c.ObservePoolEvent(evt)
+			}
+		},
+	}
+}
+
+func (r *opRecord) recordMetrics() {
+	if c := GetMetricsCollector(); c != nil {
+		c.ObserveOperation(r.Collection, r.Op, r.Duration, r.Err)
+	}
+}
+
+// DefaultBuckets 默认的耗时直方图分桶（秒）
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type opKey struct {
+	collection string
+	op         string
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type poolStats struct {
+	open            int64
+	inUse           int64
+	checkoutFailure uint64
+	cleared         uint64
+}
+
+// PrometheusCollector 在内存中汇总指标，并以 Prometheus 文本格式输出
+type PrometheusCollector struct {
+	mu        sync.Mutex
+	buckets   []float64
+	total     map[opKey]uint64
+	errors    map[opKey]uint64
+	durations map[opKey]*histogram
+	pools     map[string]*poolStats
+}
+
+// NewPrometheusCollector 创建 PrometheusCollector，buckets 为空时使用 DefaultBuckets
+func NewPrometheusCollector(buckets ...float64) *PrometheusCollector {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	return &PrometheusCollector{
+		buckets:   b,
+		total:     map[opKey]uint64{},
+		errors:    map[opKey]uint64{},
+		durations: map[opKey]*histogram{},
+		pools:     map[string]*poolStats{},
+	}
+}
+
+func (c *PrometheusCollector) ObserveOperation(collection, op string, duration time.Duration, err error) {
+	k := opKey{collection: collection, op: op}
+	sec := duration.Seconds()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total[k]++
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		c.errors[k]++
+	}
+	h, ok := c.durations[k]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(c.buckets))}
+		c.durations[k] = h
+	}
+	for i, le := range c.buckets {
+		if sec <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += sec
+	h.count++
+}
+
+func (c *PrometheusCollector) ObservePoolEvent(evt *event.PoolEvent) {
+	if evt == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.pools[evt.Address]
+	if !ok {
+		p = &poolStats{}
+		c.pools[evt.Address] = p
+	}
+	switch evt.Type {
+	case event.ConnectionCreated:
+		p.open++
+	case event.ConnectionClosed:
+		p.open--
+	case event.GetSucceeded:
+		p.inUse++
+	case event.ConnectionReturned:
+		p.inUse--
+	case event.GetFailed:
+		p.checkoutFailure++
+	case event.PoolCleared:
+		p.cleared++
+	}
+}
+
+// WriteTo 以 Prometheus 文本格式输出全部指标
+func (c *PrometheusCollector) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+
+	c.mu.Lock()
+	keys := make([]opKey, 0, len(c.total))
+	for k := range c.total {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].collection != keys[j].collection {
+			return keys[i].collection < keys[j].collection
+		}
+		return keys[i].op < keys[j].op
+	})
+	addrs := make([]string, 0, len(c.pools))
+	for a := range c.pools {
+		addrs = append(addrs, a)
+	}
+	sort.Strings(addrs)
+
+	writeHeader(&buf, "mongo_operations_total", "counter", "Total number of MongoDB operations.")
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "mongo_operations_total%s %d\n", opLabels(k), c.total[k])
+	}
+	writeHeader(&buf, "mongo_operation_errors_total", "counter", "Total number of failed MongoDB operations.")
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "mongo_operation_errors_total%s %d\n", opLabels(k), c.errors[k])
+	}
+	writeHeader(&buf, "mongo_operation_duration_seconds", "histogram", "Latency of MongoDB operations in seconds.")
+	for _, k := range keys {
+		h := c.durations[k]
+		for i, le := range c.buckets {
+			fmt.Fprintf(&buf, "mongo_operation_duration_seconds_bucket%s %d\n", opLabels(k, "le", formatFloat(le)), h.counts[i])
+		}
+		fmt.Fprintf(&buf, "mongo_operation_duration_seconds_bucket%s %d\n", opLabels(k, "le", "+Inf"), h.count)
+		fmt.Fprintf(&buf, "mongo_operation_duration_seconds_sum%s %s\n", opLabels(k), formatFloat(h.sum))
+		fmt.Fprintf(&buf, "mongo_operation_duration_seconds_count%s %d\n", opLabels(k), h.count)
+	}
+
+	writeHeader(&buf, "mongo_pool_connections_open", "gauge", "Number of open connections in the pool.")
+	for _, a := range addrs {
+		fmt.Fprintf(&buf, "mongo_pool_connections_open%s %d\n", labels("address", a), c.pools[a].open)
+	}
+	writeHeader(&buf, "mongo_pool_connections_in_use", "gauge", "Number of connections checked out of the pool.")
+	for _, a := range addrs {
+		fmt.Fprintf(&buf, "mongo_pool_connections_in_use%s %d\n", labels("address", a), c.pools[a].inUse)
+	}
+	writeHeader(&buf, "mongo_pool_checkout_failures_total", "counter", "Total number of failed connection checkouts.")
+	for _, a := range addrs {
+		fmt.Fprintf(&buf, "mongo_pool_checkout_failures_total%s %d\n", labels("address", a), c.pools[a].checkoutFailure)
+	}
+	writeHeader(&buf, "mongo_pool_cleared_total", "counter", "Total number of times the pool was cleared.")
+	for _, a := range addrs {
+		fmt.Fprintf(&buf, "mongo_pool_cleared_total%s %d\n", labels("address", a), c.pools[a].cleared)
+	}
+	c.mu.Unlock()
+
+	return buf.WriteTo(w)
+}
+
+// String 返回 Prometheus 文本格式的指标
+func (c *PrometheusCollector) String() string {
+	var sb strings.Builder
+	_, _ = c.WriteTo(&sb)
+	return sb.String()
+}
+
+// ServeHTTP 实现 http.Handler，可直接挂到 /metrics
+func (c *PrometheusCollector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = c.WriteTo(w)
+}
+
+func writeHeader(buf *bytes.Buffer, name, typ, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func opLabels(k opKey, extra ...string) string {
+	return labels(append([]string{"collection", k.collection, "op", k.op}, extra...)...)
+}
+
+// labels 按 key, value 成对输出标签
+func labels(kv ...string) string {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i := 0; i+1 < len(kv); i += 2 {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(kv[i])
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabel(kv[i+1]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
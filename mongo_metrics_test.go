@@ -0,0 +1,43 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestPrometheusCollector(t *testing.T) {
+	c := NewPrometheusCollector(0.01, 0.1)
+	c.ObserveOperation("user", "FindOne", 5*time.Millisecond, nil)
+	c.ObserveOperation("user", "FindOne", 50*time.Millisecond, mongo.ErrNoDocuments)
+	c.ObserveOperation("user", "FindOne", time.Second, errors.New("boom"))
+	c.ObservePoolEvent(&event.PoolEvent{Type: event.ConnectionCreated, Address: "localhost:27017"})
+	c.ObservePoolEvent(&event.PoolEvent{Type: event.ConnectionCreated, Address: "localhost:27017"})
+	c.ObservePoolEvent(&event.PoolEvent{Type: event.GetSucceeded, Address: "localhost:27017"})
+
+	out := c.String()
+	assert.Contains(t, out, "# TYPE mongo_operations_total counter\n")
+	assert.Contains(t, out, `mongo_operations_total{collection="user",op="FindOne"} 3`+"\n")
+	assert.Contains(t, out, `mongo_operation_errors_total{collection="user",op="FindOne"} 1`+"\n")
+	assert.Contains(t, out, `mongo_operation_duration_seconds_bucket{collection="user",op="FindOne",le="0.01"} 1`+"\n")
+	assert.Contains(t, out, `mongo_operation_duration_seconds_bucket{collection="user",op="FindOne",le="0.1"} 2`+"\n")
+	assert.Contains(t, out, `mongo_operation_duration_seconds_bucket{collection="user",op="FindOne",le="+Inf"} 3`+"\n")
+	assert.Contains(t, out, `mongo_operation_duration_seconds_count{collection="user",op="FindOne"} 3`+"\n")
+	assert.Contains(t, out, `mongo_pool_connections_open{address="localhost:27017"} 2`+"\n")
+	assert.Contains(t, out, `mongo_pool_connections_in_use{address="localhost:27017"} 1`+"\n")
+}
+
+func TestRecordMetrics(t *testing.T) {
+	c := NewPrometheusCollector()
+	SetMetricsCollector(c)
+	defer SetMetricsCollector(nil)
+
+	rec, _ := startOp(context.Background(), "order", "Count", nil, nil)
+	rec.finish(nil)
+	assert.Contains(t, c.String(), `mongo_operations_total{collection="order",op="Count"} 1`)
+}
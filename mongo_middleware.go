@@ -0,0 +1,206 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// OperationKind 操作类型
+type OperationKind string
+
+const (
+	KindFindOne          OperationKind = "FindOne"
+	KindFindList         OperationKind = "FindList"
+	KindCount            OperationKind = "Count"
+	KindInsertOne        OperationKind = "InsertOne"
+	KindUpdateOne        OperationKind = "UpdateOne"
+	KindUpdateMany       OperationKind = "UpdateMany"
+	KindReplaceOne       OperationKind = "ReplaceOne"
+	KindSoftDelete       OperationKind = "softDelete"
+	KindDeleteOne        OperationKind = "DeleteOne"
+	KindDeleteMany       OperationKind = "DeleteMany"
+	KindFindOneAndUpdate OperationKind = "FindOneAndUpdate"
+)
+
+// IsWrite 是否为写操作
+func (k OperationKind) IsWrite() bool {
+	switch k {
+	case KindFindOne, KindFindList, KindCount:
+		return false
+	default:
+		return true
+	}
+}
+
+// Operation 描述一次操作，中间件可以读取或修改其中的字段
+type Operation struct {
+	Kind       OperationKind
+	Collection string
+	Filter     interface{}
+	// Document 插入的文档，或更新时 $set 的内容（ReplaceOne 时为替换文档）
+	Document interface{}
+	// Operators 更新时除 $set 以外的操作符，如 {"$inc": {"_v": 1}}
+	Operators bson.D
+	// Options 驱动的选项，如 *options.FindOneOptions、*options.UpdateOptions
+	Options []interface{}
+}
+
+// Handler 执行一次操作，返回值类型取决于 Kind：
+// FindOne、FindOneAndUpdate 为 bson.M，FindList 为 []bson.M，Count 为 int64，
+// InsertOne 为 *mongo.InsertOneResult，更新类为 *mongo.UpdateResult，删除类为 *mongo.DeleteResult
+type Handler func(ctx context.Context, op *Operation) (interface{}, error)
+
+// Middleware 包装 Handler，可以检查、修改操作或直接返回结果
+type Middleware func(next Handler) Handler
+
+var (
+	middlewareMu sync.RWMutex
+	middlewares  []Middleware
+	// builtins 包内功能使用的中间件，位于用户中间件内层，不受 ResetMiddlewares 影响，见 init
+	builtins []Middleware
+)
+
+// init 按固定顺序注册包内中间件，外层在前：
+// audit、history 通过 next 读取修改前的文档并写入审计记录和历史版本，必须在 tenant 外层，
+// 这些读写才会按租户隔离；version 在 tenant 内层，读取当前版本时过滤条件已带上租户。
+// 顺序不能依赖各文件 init 的执行顺序，重命名文件不应影响隔离
+func init() {
+	builtins = []Middleware{auditMiddleware, historyMiddleware, tenantMiddleware, versionMiddleware}
+}
+
+// Use 注册中间件，先注册的在外层
+func Use(mw ...Middleware) {
+	middlewareMu.Lock()
+	defer middlewareMu.Unlock()
+	middlewares = append(middlewares, mw...)
+}
+
+// ResetMiddlewares 清空已注册的中间件
+func ResetMiddlewares() {
+	middlewareMu.Lock()
+	defer middlewareMu.Unlock()
+	middlewares = nil
+}
+
+// execute 依次经过中间件后执行操作，严格模式下先检查过滤条件
+func execute(ctx context.Context, op *Operation) (interface{}, error) {
+	if err := sanitizeOperation(ctx, op); err != nil {
+		return nil, err
+	}
+	middlewareMu.RLock()
+	h := Handler(runOperation)
+	for i := len(builtins) - 1; i >= 0; i-- {
+		h = builtins[i](h)
+	}
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	middlewareMu.RUnlock()
+	return h(ctx, op)
+}
+
+// runOperation 通过当前 Backend 执行操作，并记录日志、指标、追踪
+func runOperation(ctx context.Context, op *Operation) (interface{}, error) {
+	rec, ctx := startOp(ctx, op.Collection, string(op.Kind), op.Filter, op.Document)
+	rec.Operators = op.Operators
+	ctx, cancel := context.WithTimeout(ctx, timeoutSec)
+	defer cancel()
+
+	b := GetBackend()
+	var (
+		result interface{}
+		err    error
+	)
+	switch op.Kind {
+	case KindFindOne:
+		var doc bson.M
+		doc, err = b.FindOne(ctx, op)
+		if err == nil {
+			rec.N = 1
+			rec.Result = doc
+			result = doc
+		}
+	case KindFindOneAndUpdate:
+		var doc bson.M
+		doc, err = b.FindOneAndUpdate(ctx, op)
+		if err == nil {
+			rec.N = 1
+			rec.Result = doc
+			result = doc
+		}
+	case KindFindList:
+		var docs []bson.M
+		docs, err = b.FindList(ctx, op)
+		rec.N = int64(len(docs))
+		rec.Result = docs
+		result = docs
+	case KindCount:
+		var count int64
+		count, err = b.Count(ctx, op)
+		rec.N = count
+		result = count
+	case KindInsertOne:
+		var res *mongo.InsertOneResult
+		res, err = b.InsertOne(ctx, op)
+		if res != nil {
+			rec.Result = res.InsertedID
+		}
+		result = res
+	case KindDeleteOne, KindDeleteMany:
+		var res *mongo.DeleteResult
+		res, err = b.Delete(ctx, op)
+		if res != nil {
+			rec.N = res.DeletedCount
+		}
+		result = res
+	default:
+		var res *mongo.UpdateResult
+		res, err = b.Update(ctx, op)
+		if res != nil {
+			rec.Matched = res.MatchedCount
+			rec.Modified = res.ModifiedCount
+		}
+		result = res
+	}
+	rec.finish(err)
+	return result, err
+}
+
+// convertOpts 将 Options 转为驱动需要的具体类型
+func convertOpts[T any](kind OperationKind, opts []interface{}) ([]T, error) {
+	out := make([]T, 0, len(opts))
+	for _, opt := range opts {
+		o, ok := opt.(T)
+		if !ok {
+			return nil, fmt.Errorf("invalid option type for %s", kind)
+		}
+		out = append(out, o)
+	}
+	return out, nil
+}
+
+// toInterfaces 将具体类型的选项转为 []interface{}
+func toInterfaces[T any](opts []T) []interface{} {
+	out := make([]interface{}, len(opts))
+	for i, o := range opts {
+		out[i] = o
+	}
+	return out
+}
+
+// resultAs 将中间件链返回的结果转为具体类型
+func resultAs[T any](op *Operation, result interface{}, err error) (T, error) {
+	var zero T
+	if result == nil {
+		return zero, err
+	}
+	v, ok := result.(T)
+	if !ok {
+		return zero, fmt.Errorf("%s 返回了错误的结果类型 %T", op.Kind, result)
+	}
+	return v, err
+}
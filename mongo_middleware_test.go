@@ -0,0 +1,74 @@
+package mongodb
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMiddlewareChain(t *testing.T) {
+	defer ResetMiddlewares()
+
+	var order []string
+	var seen *Operation
+	// 外层中间件修改过滤条件
+	Use(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) (interface{}, error) {
+			order = append(order, "outer")
+			op.Filter = bson.M{"$and": bson.A{op.Filter, bson.M{"deleted": false}}}
+			return next(ctx, op)
+		}
+	})
+	// 内层中间件直接返回结果，不访问数据库
+	Use(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) (interface{}, error) {
+			order = append(order, "inner")
+			seen = op
+			switch op.Kind {
+			case KindFindOne:
+				return bson.M{"name": "Alice"}, nil
+			case KindUpdateOne:
+				return &mongo.UpdateResult{MatchedCount: 1}, nil
+			}
+			return nil, mongo.ErrNoDocuments
+		}
+	})
+
+	r, err := FindOne("user", bson.M{"name": "Alice"}, options.FindOne().SetSkip(1))
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"name": "Alice"}, r)
+	assert.Equal(t, []string{"outer", "inner"}, order)
+	assert.Equal(t, KindFindOne, seen.Kind)
+	assert.Equal(t, "user", seen.Collection)
+	assert.Equal(t, bson.M{"$and": bson.A{bson.M{"name": "Alice"}, bson.M{"deleted": false}}}, seen.Filter)
+	assert.Len(t, seen.Options, 1)
+
+	u, err := Update("user", "UpdateOne", bson.M{}, bson.M{"name": "Bob"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), u.MatchedCount)
+	assert.Equal(t, bson.M{"name": "Bob"}, seen.Document)
+
+	_, err = Update("user", "Unknown", bson.M{}, bson.M{})
+	assert.Error(t, err)
+
+	// 中间件返回的错误原样透传
+	_, err = Count("user", bson.M{})
+	assert.ErrorIs(t, err, mongo.ErrNoDocuments)
+}
+
+func TestBuiltinOrder(t *testing.T) {
+	// audit、history 的读写需要经过 tenant
+	var names []string
+	for _, mw := range builtins {
+		name := runtime.FuncForPC(reflect.ValueOf(mw).Pointer()).Name()
+		names = append(names, name[strings.LastIndex(name, ".")+1:])
+	}
+	assert.Equal(t, []string{"auditMiddleware", "historyMiddleware", "tenantMiddleware", "versionMiddleware"}, names)
+}
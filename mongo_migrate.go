@@ -0,0 +1,368 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MigrationZero 作为 Migrate 的目标时回滚全部迁移
+const MigrationZero = "0"
+
+// DefaultMigrationsCollection 记录已执行迁移的默认集合，锁也保存在该集合中
+const DefaultMigrationsCollection = "migrations"
+
+var (
+	// ErrMigrationLocked 其它实例正在执行迁移
+	ErrMigrationLocked = errors.New("mongodb: migration is locked by another instance")
+	// ErrMigrationLockLost 执行期间迁移锁过期并被其它实例获取，已执行的迁移不会被记录
+	ErrMigrationLockLost = errors.New("mongodb: migration lock lost")
+)
+
+// Migration 一次数据迁移，ID 按字符串排序决定执行顺序，建议使用 20261018_xxx 的形式
+type Migration struct {
+	ID          string
+	Description string
+	Up          func(ctx context.Context) error
+	// Down 回滚，为 nil 时该迁移不可回滚
+	Down func(ctx context.Context) error
+}
+
+// MigrationState 迁移的执行状态
+type MigrationState struct {
+	ID          string
+	Description string
+	Applied     bool
+	AppliedAt   string
+	// Missing 为 true 表示数据库中有记录但代码中没有注册
+	Missing bool
+}
+
+var (
+	migrationMu          sync.RWMutex
+	migrations           = map[string]Migration{}
+	migrationsCollection = DefaultMigrationsCollection
+	// MigrationLockTTL 迁移锁的过期时间，执行期间每隔 1/3 TTL 续期一次，
+	// 持锁实例异常退出后其它实例在过期后可以重新获取
+	MigrationLockTTL = 10 * time.Minute
+)
+
+const migrationLockID = "__lock__"
+
+// RegisterMigration 注册迁移，ID 为空或重复时 panic，通常在 init 中调用
+func RegisterMigration(m Migration) {
+	migrationMu.Lock()
+	defer migrationMu.Unlock()
+	if m.ID == "" || m.ID == MigrationZero || m.ID == migrationLockID {
+		panic("mongodb: invalid migration id " + m.ID)
+	}
+	if m.Up == nil {
+		panic("mongodb: migration " + m.ID + " has no Up")
+	}
+	if _, ok := migrations[m.ID]; ok {
+		panic("mongodb: duplicate migration " + m.ID)
+	}
+	migrations[m.ID] = m
+}
+
+// SetMigrationsCollection 设置记录迁移的集合
+func SetMigrationsCollection(collectionName string) {
+	migrationMu.Lock()
+	defer migrationMu.Unlock()
+	migrationsCollection = collectionName
+}
+
+func registeredMigrations() ([]Migration, string) {
+	migrationMu.RLock()
+	defer migrationMu.RUnlock()
+	list := make([]Migration, 0, len(migrations))
+	for _, m := range migrations {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, migrationsCollection
+}
+
+// appliedMigrations 返回已执行的迁移，key 为 ID，value 为执行时间
+func appliedMigrations(ctx context.Context, collectionName string) (map[string]string, error) {
+	docs, err := FindListWithContext(ctx, collectionName, bson.M{"_id": bson.M{"$ne": migrationLockID}})
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[string]string, len(docs))
+	for _, d := range docs {
+		at, _ := d["applied_at"].(string)
+		applied[fmt.Sprint(d["_id"])] = at
+	}
+	return applied, nil
+}
+
+// Status 返回所有迁移的执行状态，按 ID 排序
+func Status(ctx context.Context) ([]MigrationState, error) {
+	list, collectionName := registeredMigrations()
+	applied, err := appliedMigrations(ctx, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]MigrationState, 0, len(list))
+	for _, m := range list {
+		at, ok := applied[m.ID]
+		states = append(states, MigrationState{ID: m.ID, Description: m.Description, Applied: ok, AppliedAt: at})
+		delete(applied, m.ID)
+	}
+	for id, at := range applied {
+		states = append(states, MigrationState{ID: id, Applied: true, AppliedAt: at, Missing: true})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].ID < states[j].ID })
+	return states, nil
+}
+
+// Migrate 迁移到 target：ID 不大于 target 的未执行迁移按顺序 Up，大于 target 的已执行迁移倒序 Down。
+// target 为空时执行全部迁移，为 MigrationZero 时回滚全部。执行期间持有分布式锁，锁被占用时返回 ErrMigrationLocked
+func Migrate(ctx context.Context, target string) error {
+	return migrate(ctx, target, false)
+}
+
+// MigrateDown 只回滚 ID 大于 target 的已执行迁移，不执行未执行的迁移。
+// target 为 MigrationZero 时回滚全部，大于当前已执行的最新迁移时返回错误
+func MigrateDown(ctx context.Context, target string) error {
+	if target == "" {
+		return errors.New("mongodb: down requires a target")
+	}
+	return migrate(ctx, target, true)
+}
+
+func migrate(ctx context.Context, target string, downOnly bool) error {
+	list, collectionName := registeredMigrations()
+	if target != "" && target != MigrationZero {
+		found := false
+		for _, m := range list {
+			if m.ID == target {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("mongodb: unknown migration %s", target)
+		}
+	}
+
+	lock, err := acquireMigrationLock(ctx, collectionName)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	applied, err := appliedMigrations(ctx, collectionName)
+	if err != nil {
+		return err
+	}
+	if downOnly && target != MigrationZero {
+		current := ""
+		for id := range applied {
+			if id > current {
+				current = id
+			}
+		}
+		if target > current {
+			return fmt.Errorf("mongodb: down target %s is above current version %q", target, current)
+		}
+	}
+
+	// 回滚
+	for i := len(list) - 1; i >= 0; i-- {
+		m := list[i]
+		if _, ok := applied[m.ID]; !ok || !migrationAfter(m.ID, target) {
+			continue
+		}
+		if m.Down == nil {
+			return fmt.Errorf("mongodb: migration %s cannot be rolled back", m.ID)
+		}
+		logf(LevelInfo, "migration down", Field{Key: "id", Value: m.ID})
+		if err := m.Down(ctx); err != nil {
+			return fmt.Errorf("mongodb: migration %s down: %w", m.ID, err)
+		}
+		if err := lock.check(ctx); err != nil {
+			return fmt.Errorf("mongodb: migration %s down: %w", m.ID, err)
+		}
+		if _, err := DeleteWithContext(ctx, collectionName, string(KindDeleteOne), bson.M{"_id": m.ID}); err != nil {
+			return err
+		}
+	}
+	if downOnly {
+		return nil
+	}
+
+	// 执行
+	for _, m := range list {
+		if _, ok := applied[m.ID]; ok || migrationAfter(m.ID, target) {
+			continue
+		}
+		logf(LevelInfo, "migration up", Field{Key: "id", Value: m.ID})
+		if err := m.Up(ctx); err != nil {
+			return fmt.Errorf("mongodb: migration %s up: %w", m.ID, err)
+		}
+		if err := lock.check(ctx); err != nil {
+			return fmt.Errorf("mongodb: migration %s up: %w", m.ID, err)
+		}
+		_, err := InsertOneWithContext(ctx, collectionName, bson.D{
+			{Key: "_id", Value: m.ID},
+			{Key: "description", Value: m.Description},
+			{Key: "applied_at", Value: time.Now().Format(TimeLayout)},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrationAfter id 是否在 target 之后，target 为空表示最新
+func migrationAfter(id, target string) bool {
+	switch target {
+	case "":
+		return false
+	case MigrationZero:
+		return true
+	}
+	return id > target
+}
+
+// migrationLock 持有中的迁移锁
+type migrationLock struct {
+	collection string
+	owner      string
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// acquireMigrationLock 通过带过期时间的 upsert 获取锁：锁未过期且属于其它实例时，
+// 过滤条件不匹配，upsert 因 _id 重复失败。获取后在后台续期，直到 release
+func acquireMigrationLock(ctx context.Context, collectionName string) (*migrationLock, error) {
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())
+	now := time.Now()
+	_, err := FindOneAndUpdateWithContext(ctx, collectionName,
+		bson.M{"_id": migrationLockID, "expire_at": bson.M{"$lt": now}},
+		bson.M{"owner": owner, "expire_at": now.Add(MigrationLockTTL)}, nil,
+		options.FindOneAndUpdate().SetUpsert(true))
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrMigrationLocked
+		}
+		return nil, err
+	}
+	l := &migrationLock{collection: collectionName, owner: owner, stop: make(chan struct{}), done: make(chan struct{})}
+	go l.heartbeat(MigrationLockTTL)
+	return l, nil
+}
+
+// heartbeat 每隔 ttl/3 延长过期时间，锁已不属于自己时停止
+func (l *migrationLock) heartbeat(ttl time.Duration) {
+	defer close(l.done)
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeoutSec)
+		res, err := UpdateWithContext(ctx, l.collection, string(KindUpdateOne),
+			bson.M{"_id": migrationLockID, "owner": l.owner}, bson.M{"expire_at": time.Now().Add(ttl)})
+		cancel()
+		switch {
+		case err != nil:
+			logf(LevelError, "迁移锁续期失败："+err.Error())
+		case res.MatchedCount == 0:
+			logf(LevelError, "迁移锁已被其它实例获取")
+			return
+		}
+	}
+}
+
+// check 确认锁仍属于自己，记录迁移结果之前调用
+func (l *migrationLock) check(ctx context.Context) error {
+	_, err := FindOneWithContext(ctx, l.collection, bson.M{"_id": migrationLockID, "owner": l.owner})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrMigrationLockLost
+	}
+	return err
+}
+
+// release 停止续期并删除锁
+func (l *migrationLock) release() {
+	close(l.stop)
+	<-l.done
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutSec)
+	defer cancel()
+	_, err := DeleteWithContext(ctx, l.collection, string(KindDeleteOne), bson.M{"_id": migrationLockID, "owner": l.owner})
+	if err != nil {
+		logf(LevelError, "释放迁移锁失败："+err.Error())
+	}
+}
+
+// RunMigrationCLI 供命令行使用的迁移入口，args 通常为 os.Args[1:]：
+//
+//	up [target]    执行到 target，省略时执行全部
+//	down <target>  回滚到 target，0 表示全部回滚
+//	status         输出迁移状态
+func RunMigrationCLI(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: up [target] | down <target> | status")
+	}
+	switch args[0] {
+	case "up":
+		target := ""
+		if len(args) > 1 {
+			target = args[1]
+		}
+		if err := Migrate(ctx, target); err != nil {
+			return err
+		}
+	case "down":
+		if len(args) < 2 {
+			return errors.New("usage: down <target>")
+		}
+		if err := MigrateDown(ctx, args[1]); err != nil {
+			return err
+		}
+	case "status":
+	default:
+		return fmt.Errorf("unknown command %q, usage: up [target] | down <target> | status", args[0])
+	}
+
+	states, err := Status(ctx)
+	if err != nil {
+		return err
+	}
+	for _, s := range states {
+		mark := "[ ]"
+		if s.Applied {
+			mark = "[x]"
+		}
+		line := fmt.Sprintf("%s %s", mark, s.ID)
+		if s.Description != "" {
+			line += "  " + s.Description
+		}
+		if s.AppliedAt != "" {
+			line += "  (" + s.AppliedAt + ")"
+		}
+		if s.Missing {
+			line += "  (missing)"
+		}
+		fmt.Fprintln(out, strings.TrimSpace(line))
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package mongodb
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func resetMigrations() {
+	migrationMu.Lock()
+	migrations = map[string]Migration{}
+	migrationMu.Unlock()
+}
+
+func TestMigrate(t *testing.T) {
+	m := useTestMemoryBackend(t)
+	defer resetMigrations()
+
+	var calls []string
+	for _, id := range []string{"20261001_a", "20261002_b", "20261003_c"} {
+		id := id
+		RegisterMigration(Migration{
+			ID:   id,
+			Up:   func(ctx context.Context) error { calls = append(calls, "up "+id); return nil },
+			Down: func(ctx context.Context) error { calls = append(calls, "down "+id); return nil },
+		})
+	}
+	assert.Panics(t, func() { RegisterMigration(Migration{ID: "20261001_a", Up: func(context.Context) error { return nil }}) })
+
+	ctx := context.Background()
+	assert.NoError(t, Migrate(ctx, "20261002_b"))
+	assert.Equal(t, []string{"up 20261001_a", "up 20261002_b"}, calls)
+	assert.Len(t, m.Documents(DefaultMigrationsCollection), 2)
+
+	calls = nil
+	assert.NoError(t, Migrate(ctx, ""))
+	assert.Equal(t, []string{"up 20261003_c"}, calls)
+
+	calls = nil
+	var out bytes.Buffer
+	assert.NoError(t, RunMigrationCLI(ctx, []string{"down", "20261001_a"}, &out))
+	assert.Equal(t, []string{"down 20261003_c", "down 20261002_b"}, calls)
+	assert.Contains(t, out.String(), "[x] 20261001_a")
+	assert.Contains(t, out.String(), "[ ] 20261003_c")
+
+	// down 不执行未执行的迁移，目标高于当前版本时报错
+	calls = nil
+	assert.Error(t, RunMigrationCLI(ctx, []string{"down", "20261003_c"}, &out))
+	assert.Empty(t, calls)
+	assert.NoError(t, RunMigrationCLI(ctx, []string{"down", "0"}, &out))
+	assert.Equal(t, []string{"down 20261001_a"}, calls)
+	assert.Empty(t, m.Documents(DefaultMigrationsCollection))
+
+	assert.Error(t, Migrate(ctx, "unknown"))
+	assert.Error(t, RunMigrationCLI(ctx, nil, &out))
+}
+
+func TestMigrationLock(t *testing.T) {
+	m := useTestMemoryBackend(t)
+	defer resetMigrations()
+	prevTTL := MigrationLockTTL
+	MigrationLockTTL = 60 * time.Millisecond
+	defer func() { MigrationLockTTL = prevTTL }()
+
+	ctx := context.Background()
+	RegisterMigration(Migration{ID: "20261001_slow", Up: func(ctx context.Context) error {
+		// 执行时间超过 TTL，锁持续续期，其它实例无法获取
+		for i := 0; i < 6; i++ {
+			time.Sleep(20 * time.Millisecond)
+			assert.ErrorIs(t, Migrate(ctx, ""), ErrMigrationLocked)
+		}
+		return nil
+	}})
+	assert.NoError(t, Migrate(ctx, ""))
+	assert.Len(t, m.Documents(DefaultMigrationsCollection), 1)
+
+	// 锁被其它实例获取后不记录迁移结果
+	RegisterMigration(Migration{ID: "20261002_lost", Up: func(ctx context.Context) error {
+		_, err := UpdateWithContext(ctx, DefaultMigrationsCollection, string(KindUpdateOne),
+			bson.M{"_id": migrationLockID}, bson.M{"owner": "other"})
+		return err
+	}})
+	assert.ErrorIs(t, Migrate(ctx, ""), ErrMigrationLockLost)
+	_, err := FindOneWithContext(ctx, DefaultMigrationsCollection, bson.M{"_id": "20261002_lost"})
+	assert.ErrorIs(t, err, mongo.ErrNoDocuments)
+}
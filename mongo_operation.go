@@ -0,0 +1,121 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// opRecord 记录一次操作的元信息，供日志等使用
+type opRecord struct {
+	Collection string
+	// Database WithDatabase 指定的数据库，为空时为默认数据库
+	Database string
+	Op       string
+	Filter   interface{}
+	Document interface{}
+	// Operators 更新时除 $set 以外的操作符，用于 explain
+	Operators bson.D
+	Result    interface{}
+	Start     time.Time
+	Duration  time.Duration
+	// Matched/Modified 对应更新类操作，N 对应查询返回条数或 Count 结果
+	Matched  int64
+	Modified int64
+	N        int64
+	Err      error
+	// Caller/Function 调用方位置，只在开启慢操作检测时记录
+	Caller   string
+	Function string
+
+	span Span
+}
+
+// startOp 开始一次操作，返回的 context 携带追踪信息，应作为后续驱动调用的父 context
+func startOp(ctx context.Context, collection, op string, filter, document interface{}) (*opRecord, context.Context) {
+	r := &opRecord{
+		Collection: collection,
+		Database:   DatabaseFromContext(ctx),
+		Op:         op,
+		Filter:     filter,
+		Document:   document,
+	}
+	if GetSlowQueryOptions().Threshold > 0 {
+		file, line, function := callerOutsidePackage()
+		r.Caller = fmt.Sprintf("%s:%d", file, line)
+		r.Function = function
+	}
+	ctx = r.startSpan(ctx)
+	r.Start = time.Now()
+	return r, ctx
+}
+
+// databaseName 返回操作使用的数据库名
+func (r *opRecord) databaseName() string {
+	if r.Database != "" {
+		return r.Database
+	}
+	return DatabaseName()
+}
+
+// finish 结束一次操作，输出日志、记录指标、结束追踪并检测慢操作
+func (r *opRecord) finish(err error) {
+	r.Duration = time.Since(r.Start)
+	r.Err = err
+	r.endSpan()
+	r.log()
+	r.recordMetrics()
+	r.checkSlow()
+}
+
+func (r *opRecord) log() {
+	_, o := currentLogger()
+	level := LevelDebug
+	msg := "mongo operation"
+	if r.Err != nil {
+		if errors.Is(r.Err, mongo.ErrNoDocuments) {
+			level = LevelDebug
+		} else {
+			level = LevelError
+			msg = "mongo operation failed"
+		}
+	}
+	if level < o.Level || o.Level >= LevelOff {
+		return
+	}
+
+	fields := []Field{
+		{Key: "collection", Value: r.Collection},
+		{Key: "op", Value: r.Op},
+		{Key: "duration", Value: r.Duration},
+	}
+	switch r.Op {
+	case "FindOne", "FindList", "Count", "DeleteOne", "DeleteMany", "FindOneAndUpdate":
+		fields = append(fields, Field{Key: "n", Value: r.N})
+	case "InsertOne":
+	default:
+		fields = append(fields,
+			Field{Key: "matched", Value: r.Matched},
+			Field{Key: "modified", Value: r.Modified},
+		)
+	}
+	if r.Err != nil {
+		fields = append(fields, Field{Key: "error", Value: r.Err.Error()})
+	}
+	if !o.MetadataOnly {
+		if r.Filter != nil {
+			fields = append(fields, Field{Key: "filter", Value: Redact(r.Filter, o.RedactRules...)})
+		}
+		if r.Document != nil {
+			fields = append(fields, Field{Key: "document", Value: Redact(r.Document, o.RedactRules...)})
+		}
+		if r.Result != nil {
+			fields = append(fields, Field{Key: "result", Value: Redact(r.Result, o.RedactRules...)})
+		}
+	}
+	logf(level, msg, fields...)
+}
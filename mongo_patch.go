@@ -0,0 +1,227 @@
+package mongodb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// PatchState PatchField 的状态
+type PatchState int
+
+const (
+	// PatchUnchanged 不修改，PatchField 的零值
+	PatchUnchanged PatchState = iota
+	// PatchSet 设置为值
+	PatchSet
+	// PatchNull 设置为 null
+	PatchNull
+	// PatchUnset 删除字段
+	PatchUnset
+)
+
+// PatchField 用于局部更新的字段包装，区分“不修改”、“设为值”、“设为 null”和“删除字段”。
+// 从 JSON 解码时，缺少该键为不修改，null 为设为 null，其它为设为值
+type PatchField[T any] struct {
+	value T
+	state PatchState
+}
+
+// SetField 返回设置为 v 的 PatchField
+func SetField[T any](v T) PatchField[T] {
+	return PatchField[T]{value: v, state: PatchSet}
+}
+
+// NullField 返回设置为 null 的 PatchField
+func NullField[T any]() PatchField[T] {
+	return PatchField[T]{state: PatchNull}
+}
+
+// UnsetField 返回删除字段的 PatchField
+func UnsetField[T any]() PatchField[T] {
+	return PatchField[T]{state: PatchUnset}
+}
+
+// State 返回状态
+func (f PatchField[T]) State() PatchState {
+	return f.state
+}
+
+// Value 返回值，状态不是 PatchSet 时 ok 为 false
+func (f PatchField[T]) Value() (v T, ok bool) {
+	return f.value, f.state == PatchSet
+}
+
+func (f PatchField[T]) patchValue() (PatchState, interface{}) {
+	return f.state, f.value
+}
+
+// UnmarshalJSON null 解码为 PatchNull，其它解码为 PatchSet
+func (f *PatchField[T]) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		*f = NullField[T]()
+		return nil
+	}
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*f = SetField(v)
+	return nil
+}
+
+// MarshalJSON PatchSet 编码为值，其它状态编码为 null
+func (f PatchField[T]) MarshalJSON() ([]byte, error) {
+	if f.state != PatchSet {
+		return []byte("null"), nil
+	}
+	return json.Marshal(f.value)
+}
+
+// patchValuer 由 PatchField 实现，用于在反射中识别
+type patchValuer interface {
+	patchValue() (PatchState, interface{})
+}
+
+var (
+	patchValuerType    = reflect.TypeOf((*patchValuer)(nil)).Elem()
+	bsonMarshalerType  = reflect.TypeOf((*bson.Marshaler)(nil)).Elem()
+	valueMarshalerType = reflect.TypeOf((*bsoncodec.ValueMarshaler)(nil)).Elem()
+)
+
+// Struct2SetFields 将结构体展开为点路径的 $set 和 $unset 字段，只修改结构体中给出的字段：
+// 嵌套结构体展开为 a.b 形式而不是整体覆盖，nil 指针和 omitempty 的空值表示不修改，
+// PatchField 按其状态设置值、设置 null 或删除字段。map、切片和 time.Time 等类型整体设置
+func Struct2SetFields(doc interface{}) (set bson.D, unset bson.D, err error) {
+	v := reflect.ValueOf(doc)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil, fmt.Errorf("Struct2SetFields 的参数不能为 nil")
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("Struct2SetFields 需要结构体，实际为 %s", v.Kind())
+	}
+	set, unset = bson.D{}, bson.D{}
+	flattenFields(v, "", &set, &unset)
+	return set, unset, nil
+}
+
+func flattenFields(v reflect.Value, prefix string, set, unset *bson.D) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, inline, skip := bsonFieldName(sf)
+		if skip {
+			continue
+		}
+		fv := v.Field(i)
+		if inline {
+			for fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					break
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				flattenFields(fv, prefix, set, unset)
+			}
+			continue
+		}
+		path := prefix + name
+
+		if fv.Kind() == reflect.Ptr && fv.IsNil() {
+			// 包括 nil 的 *PatchField
+			continue
+		}
+		if pv, ok := fv.Interface().(patchValuer); ok {
+			switch state, value := pv.patchValue(); state {
+			case PatchSet:
+				flattenValue(reflect.ValueOf(value), path, set, unset)
+			case PatchNull:
+				*set = append(*set, bson.E{Key: path, Value: nil})
+			case PatchUnset:
+				*unset = append(*unset, bson.E{Key: path, Value: ""})
+			}
+			continue
+		}
+		if hasTagOption(sf, "omitempty") && isEmptyValue(fv) {
+			continue
+		}
+		flattenValue(fv, path, set, unset)
+	}
+}
+
+// flattenValue 结构体继续展开，其它值整体设置
+func flattenValue(fv reflect.Value, path string, set, unset *bson.D) {
+	for fv.Kind() == reflect.Ptr && !fv.IsNil() && !customBSON(fv.Type()) {
+		fv = fv.Elem()
+	}
+	if fv.Kind() == reflect.Struct && !customBSON(fv.Type()) {
+		flattenFields(fv, path+".", set, unset)
+		return
+	}
+	*set = append(*set, bson.E{Key: path, Value: fv.Interface()})
+}
+
+// customBSON 有自己编码方式的类型，不能按字段展开
+func customBSON(t reflect.Type) bool {
+	switch t {
+	case timeType, objectIDType, dateTimeType, decimalType, timestampType, binaryType:
+		return true
+	}
+	return t.Implements(bsonMarshalerType) || t.Implements(valueMarshalerType) ||
+		reflect.PointerTo(t).Implements(bsonMarshalerType) || reflect.PointerTo(t).Implements(valueMarshalerType)
+}
+
+func hasTagOption(sf reflect.StructField, option string) bool {
+	parts := strings.Split(sf.Tag.Get("bson"), ",")
+	for _, p := range parts[1:] {
+		if p == option {
+			return true
+		}
+	}
+	return false
+}
+
+// Patch 局部更新：document 经 Struct2SetFields 展开后 $set 和 $unset，并写入 update_time，
+// updateType 为 UpdateOne 或 UpdateMany
+func Patch(collectionName, updateType string, filter interface{}, document interface{}, opts ...interface{}) (*mongo.UpdateResult, error) {
+	return PatchWithContext(context.Background(), collectionName, updateType, filter, document, opts...)
+}
+
+// PatchWithContext 同 Patch，钩子在展开字段之前调用
+func PatchWithContext(ctx context.Context, collectionName, updateType string, filter interface{}, document interface{}, opts ...interface{}) (*mongo.UpdateResult, error) {
+	switch OperationKind(updateType) {
+	case KindUpdateOne, KindUpdateMany:
+	default:
+		return nil, fmt.Errorf("updateType 参数错误")
+	}
+	document, err := runBeforeUpdate(ctx, document)
+	if err != nil {
+		return nil, err
+	}
+	set, unset, err := Struct2SetFields(document)
+	if err != nil {
+		return nil, err
+	}
+	set = append(set, bson.E{Key: "update_time", Value: time.Now().Format(TimeLayout)})
+	op := &Operation{Kind: OperationKind(updateType), Collection: collectionName, Filter: filter, Document: set, Options: opts}
+	if len(unset) > 0 {
+		op.Operators = bson.D{{Key: "$unset", Value: unset}}
+	}
+	result, err := execute(ctx, op)
+	return resultAs[*mongo.UpdateResult](op, result, err)
+}
@@ -0,0 +1,139 @@
+package mongodb
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type patchAddress struct {
+	City   string `bson:"city,omitempty"`
+	Street string `bson:"street,omitempty"`
+}
+
+type patchMeta struct {
+	Source string `bson:"source"`
+}
+
+// PatchInline 内联的结构体需要导出
+type PatchInline struct {
+	Source string `bson:"source"`
+}
+
+type patchUser struct {
+	Name        *string               `bson:"name"`
+	Age         int                   `bson:"age,omitempty"`
+	Address     *patchAddress         `bson:"address"`
+	Tags        []string              `bson:"tags,omitempty"`
+	Nickname    PatchField[string]    `bson:"nickname" json:"nickname"`
+	Avatar      PatchField[string]    `bson:"avatar" json:"avatar"`
+	Birthday    time.Time             `bson:"birthday,omitempty"`
+	Extra       PatchField[patchMeta] `bson:"extra" json:"extra"`
+	PatchInline `bson:",inline"`
+}
+
+func TestStruct2SetFields(t *testing.T) {
+	name := "Alice"
+	set, unset, err := Struct2SetFields(patchUser{
+		Name:        &name,
+		Address:     &patchAddress{City: "Beijing"},
+		Nickname:    NullField[string](),
+		Avatar:      UnsetField[string](),
+		Extra:       SetField(patchMeta{Source: "api"}),
+		PatchInline: PatchInline{Source: "web"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{
+		{Key: "name", Value: "Alice"},
+		{Key: "address.city", Value: "Beijing"},
+		{Key: "nickname", Value: nil},
+		{Key: "extra.source", Value: "api"},
+		{Key: "source", Value: "web"},
+	}, set)
+	assert.Equal(t, bson.D{{Key: "avatar", Value: ""}}, unset)
+
+	// nil 的 *PatchField 表示不修改
+	avatar := UnsetField[string]()
+	set, unset, err = Struct2SetFields(struct {
+		Nickname *PatchField[string] `bson:"nickname"`
+		Avatar   *PatchField[string] `bson:"avatar"`
+	}{Avatar: &avatar})
+	assert.NoError(t, err)
+	assert.Empty(t, set)
+	assert.Equal(t, bson.D{{Key: "avatar", Value: ""}}, unset)
+
+	_, _, err = Struct2SetFields(bson.M{})
+	assert.Error(t, err)
+}
+
+func TestPatchFieldJSON(t *testing.T) {
+	var u patchUser
+	assert.NoError(t, json.Unmarshal([]byte(`{"nickname": null, "avatar": "a.png"}`), &u))
+	assert.Equal(t, PatchNull, u.Nickname.State())
+	v, ok := u.Avatar.Value()
+	assert.True(t, ok)
+	assert.Equal(t, "a.png", v)
+	assert.Equal(t, PatchUnchanged, u.Extra.State())
+}
+
+func TestPatch(t *testing.T) {
+	m := useTestMemoryBackend(t)
+	assert.NoError(t, m.Seed("user", bson.M{
+		"_id": 1, "name": "Alice", "age": 30, "avatar": "a.png",
+		"address": bson.M{"city": "Beijing", "street": "Main"},
+	}))
+
+	res, err := NewRepository[patchUser]("user").PatchStruct(context.Background(), 1, &patchUser{
+		Address: &patchAddress{City: "Shanghai"},
+		Avatar:  UnsetField[string](),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), res.ModifiedCount)
+
+	doc := m.Documents("user")[0]
+	assert.Equal(t, "Alice", doc["name"])
+	assert.Equal(t, bson.M{"city": "Shanghai", "street": "Main"}, doc["address"])
+	assert.NotContains(t, doc, "avatar")
+	assert.NotEmpty(t, doc["update_time"])
+
+	_, err = Patch("user", string(KindReplaceOne), bson.M{}, patchUser{})
+	assert.Error(t, err)
+}
+
+type patchProfile struct {
+	Name  PatchField[string]       `bson:"name" validate:"required,min=2"`
+	Email PatchField[string]       `bson:"email" validate:"email"`
+	Home  PatchField[patchContact] `bson:"home"`
+}
+
+type patchContact struct {
+	City string `bson:"city" validate:"required"`
+}
+
+func TestPatchValidate(t *testing.T) {
+	m := useTestMemoryBackend(t)
+	assert.NoError(t, m.Seed("user", bson.M{"_id": 1, "name": "Alice"}))
+
+	// 只校验给出的字段，校验的是其中的值
+	_, err := PatchWithContext(context.Background(), "user", string(KindUpdateOne), bson.M{"_id": 1},
+		&patchProfile{Email: SetField("x@y.com")})
+	assert.NoError(t, err)
+	assert.Equal(t, "x@y.com", m.Documents("user")[0]["email"])
+
+	var verrs ValidationErrors
+	_, err = PatchWithContext(context.Background(), "user", string(KindUpdateOne), bson.M{"_id": 1},
+		&patchProfile{Email: SetField("bad"), Home: SetField(patchContact{})})
+	if assert.ErrorAs(t, err, &verrs) {
+		assert.Equal(t, []string{"email", "home.city"}, []string{verrs[0].Field, verrs[1].Field})
+	}
+	// 必填字段不能设为 null 或删除
+	err = ValidateStruct(patchProfile{Name: NullField[string]()})
+	if assert.ErrorAs(t, err, &verrs) {
+		assert.Equal(t, "required", verrs[0].Tag)
+	}
+	assert.Error(t, ValidateStruct(patchProfile{Name: SetField("A")}))
+}
@@ -0,0 +1,137 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repository 绑定到一个集合的类型化数据访问对象，避免在调用处反复传集合名
+type Repository[T any] struct {
+	collectionName string
+}
+
+// NewRepository 创建绑定到 collectionName 的 Repository
+func NewRepository[T any](collectionName string) *Repository[T] {
+	return &Repository[T]{collectionName: collectionName}
+}
+
+// CollectionName 返回绑定的集合名
+func (r *Repository[T]) CollectionName() string {
+	return r.collectionName
+}
+
+// Page 分页查询结果
+type Page[T any] struct {
+	Items    []T   `json:"items"`
+	Total    int64 `json:"total"`
+	Page     int64 `json:"page"`
+	PageSize int64 `json:"page_size"`
+}
+
+// idFilter 合法的 ObjectID 十六进制字符串会转为 ObjectID，其余类型原样作为 _id
+func idFilter(id interface{}) bson.M {
+	if s, ok := id.(string); ok {
+		if oid, err := primitive.ObjectIDFromHex(s); err == nil {
+			return bson.M{"_id": oid}
+		}
+	}
+	return bson.M{"_id": id}
+}
+
+func orEmpty(filter interface{}) interface{} {
+	if filter == nil {
+		return bson.M{}
+	}
+	return filter
+}
+
+// Get 按 _id 查找，未找到时返回 mongo.ErrNoDocuments
+func (r *Repository[T]) Get(ctx context.Context, id interface{}) (*T, error) {
+	return FindOneAs[T](ctx, r.collectionName, idFilter(id))
+}
+
+// FindOne 按条件查找一条
+func (r *Repository[T]) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) (*T, error) {
+	return FindOneAs[T](ctx, r.collectionName, orEmpty(filter), opts...)
+}
+
+// Find 按条件查找多条
+func (r *Repository[T]) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]T, error) {
+	return FindListAs[T](ctx, r.collectionName, orEmpty(filter), opts...)
+}
+
+// FindPage 分页查找，page 从 1 开始，sort 使用 ParseSortString 的格式，为空时不排序
+func (r *Repository[T]) FindPage(ctx context.Context, filter interface{}, page, pageSize int64, sort string) (*Page[T], error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	filter = orEmpty(filter)
+
+	opts := options.Find().SetSkip((page - 1) * pageSize).SetLimit(pageSize)
+	if sort != "" {
+		sortClause, err := ParseSortStringD(sort)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetSort(sortClause)
+	}
+
+	total, err := CountWithContext(ctx, r.collectionName, filter)
+	if err != nil {
+		return nil, err
+	}
+	items, err := FindListAs[T](ctx, r.collectionName, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Page[T]{Items: items, Total: total, Page: page, PageSize: pageSize}, nil
+}
+
+// Create 插入文档并写入 create_time
+func (r *Repository[T]) Create(ctx context.Context, doc *T) (*mongo.InsertOneResult, error) {
+	return InsertOneWithCreateTimeContext(ctx, r.collectionName, doc)
+}
+
+// Update 按 _id 用整个结构体 $set 更新并写入 update_time
+func (r *Repository[T]) Update(ctx context.Context, id interface{}, doc *T) (*mongo.UpdateResult, error) {
+	return UpdateWithUpdateTimeContext(ctx, r.collectionName, string(KindUpdateOne), idFilter(id), doc)
+}
+
+// Patch 按 _id 只 $set 指定字段并写入 update_time
+func (r *Repository[T]) Patch(ctx context.Context, id interface{}, fields bson.M) (*mongo.UpdateResult, error) {
+	set, err := MapToBsonD(fields)
+	if err != nil {
+		return nil, err
+	}
+	set = append(set, bson.E{Key: "update_time", Value: time.Now().Format(TimeLayout)})
+	return UpdateWithContext(ctx, r.collectionName, string(KindUpdateOne), idFilter(id), set)
+}
+
+// PatchStruct 按 _id 用 Struct2SetFields 展开后的字段局部更新，见 Patch
+func (r *Repository[T]) PatchStruct(ctx context.Context, id interface{}, patch interface{}) (*mongo.UpdateResult, error) {
+	return PatchWithContext(ctx, r.collectionName, string(KindUpdateOne), idFilter(id), patch)
+}
+
+// Delete 按 _id 删除
+func (r *Repository[T]) Delete(ctx context.Context, id interface{}) (*mongo.DeleteResult, error) {
+	return DeleteWithContext(ctx, r.collectionName, string(KindDeleteOne), idFilter(id))
+}
+
+// Count 按条件计数
+func (r *Repository[T]) Count(ctx context.Context, filter interface{}) (int64, error) {
+	return CountWithContext(ctx, r.collectionName, orEmpty(filter))
+}
+
+// Exists 是否存在满足条件的文档
+func (r *Repository[T]) Exists(ctx context.Context, filter interface{}) (bool, error) {
+	n, err := CountWithContext(ctx, r.collectionName, orEmpty(filter), options.Count().SetLimit(1))
+	return n > 0, err
+}
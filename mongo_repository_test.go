@@ -0,0 +1,74 @@
+package mongodb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type repoProject struct {
+	ID   primitive.ObjectID `bson:"_id,omitempty"`
+	Name string             `bson:"name"`
+}
+
+func TestRepository(t *testing.T) {
+	defer ResetMiddlewares()
+	var ops []Operation
+	Use(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) (interface{}, error) {
+			ops = append(ops, *op)
+			switch op.Kind {
+			case KindFindOne:
+				return bson.M{"name": "p1"}, nil
+			case KindFindList:
+				return []bson.M{{"name": "p1"}, {"name": "p2"}}, nil
+			case KindCount:
+				return int64(12), nil
+			case KindUpdateOne:
+				return &mongo.UpdateResult{MatchedCount: 1}, nil
+			case KindDeleteOne:
+				return &mongo.DeleteResult{DeletedCount: 1}, nil
+			}
+			return &mongo.InsertOneResult{}, nil
+		}
+	})
+
+	ctx := context.Background()
+	repo := NewRepository[repoProject]("project")
+	id := primitive.NewObjectID()
+
+	p, err := repo.Get(ctx, id.Hex())
+	assert.NoError(t, err)
+	assert.Equal(t, "p1", p.Name)
+	assert.Equal(t, bson.M{"_id": id}, ops[0].Filter)
+
+	page, err := repo.FindPage(ctx, nil, 2, 5, "name=-1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), page.Total)
+	assert.Len(t, page.Items, 2)
+	findOpts := ops[len(ops)-1].Options[0].(*options.FindOptions)
+	assert.Equal(t, int64(5), *findOpts.Skip)
+	assert.Equal(t, bson.D{{Key: "name", Value: -1}}, findOpts.Sort)
+
+	_, err = repo.Create(ctx, &repoProject{Name: "p3"})
+	assert.NoError(t, err)
+	assert.Equal(t, "create_time", ops[len(ops)-1].Document.(bson.D)[1].Key)
+
+	_, err = repo.Patch(ctx, id, bson.M{"name": "p4"})
+	assert.NoError(t, err)
+	assert.Equal(t, "update_time", ops[len(ops)-1].Document.(bson.D)[1].Key)
+
+	res, err := repo.Delete(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), res.DeletedCount)
+	assert.Equal(t, KindDeleteOne, ops[len(ops)-1].Kind)
+
+	ok, err := repo.Exists(ctx, bson.M{"name": "p1"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
@@ -0,0 +1,236 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrUnsafeFilter 过滤条件中含有不允许的操作符
+var ErrUnsafeFilter = errors.New("mongodb: unsafe filter")
+
+// FilterError 描述过滤条件中不允许的操作符及其位置，errors.Is(err, ErrUnsafeFilter) 为 true
+type FilterError struct {
+	// Path 操作符所在的路径，如 password、$or.0.name，顶层操作符为空
+	Path     string
+	Operator string
+}
+
+func (e *FilterError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("mongodb: unsafe filter, operator %s is not allowed", e.Operator)
+	}
+	return fmt.Sprintf("mongodb: unsafe filter, operator %s is not allowed at %q", e.Operator, e.Path)
+}
+
+func (e *FilterError) Unwrap() error {
+	return ErrUnsafeFilter
+}
+
+// ForbiddenOperators 执行 JavaScript 或表达式的操作符，任何配置下都不允许出现在外部传入的过滤条件中
+var ForbiddenOperators = []string{"$where", "$function", "$accumulator", "$expr"}
+
+// SafeOperators 常用的比较和逻辑操作符，需要开放查询能力时可作为 AllowedOperators。
+// 不包括可能导致慢查询的 $regex
+var SafeOperators = []string{
+	"$eq", "$ne", "$gt", "$gte", "$lt", "$lte", "$in", "$nin", "$exists",
+	"$all", "$size", "$elemMatch", "$not", "$and", "$or", "$nor",
+}
+
+// SanitizeOptions 过滤条件的检查规则
+type SanitizeOptions struct {
+	// AllowedOperators 允许的操作符，为空时不允许任何操作符，ForbiddenOperators 始终不允许
+	AllowedOperators []string
+	// Escape 为 true 时字段上不允许的操作符按字面值匹配（包装为 $eq）而不是报错，
+	// 如 {"password": {"$ne": null}} 只匹配值恰好为 {"$ne": null} 的文档。
+	// 顶层的操作符和 ForbiddenOperators 无法转义，仍然报错
+	Escape bool
+}
+
+func (o SanitizeOptions) allowed(op string) bool {
+	for _, f := range ForbiddenOperators {
+		if op == f {
+			return false
+		}
+	}
+	for _, a := range o.AllowedOperators {
+		if op == a {
+			return true
+		}
+	}
+	return false
+}
+
+// SanitizeFilter 检查外部传入（如 JSON 解码）的过滤条件，拒绝或转义其中不允许的 $ 操作符，
+// 返回可以直接使用的过滤条件。不传 opts 时只允许字段等值匹配
+func SanitizeFilter(filter interface{}, opts ...SanitizeOptions) (bson.M, error) {
+	var o SanitizeOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	doc, err := normalizeDoc(filter)
+	if err != nil {
+		return nil, err
+	}
+	if err := sanitizeQuery(doc, "", o); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// sanitizeQuery 检查一层查询条件，会就地转义字段的值
+func sanitizeQuery(query bson.M, prefix string, o SanitizeOptions) error {
+	for _, key := range sortByMapKeys(query) {
+		value := query[key]
+		if strings.HasPrefix(key, "$") {
+			if !o.allowed(key) {
+				return &FilterError{Path: strings.TrimSuffix(prefix, "."), Operator: key}
+			}
+			switch key {
+			case "$and", "$or", "$nor":
+				subs, _ := value.(bson.A)
+				for i, sub := range subs {
+					sm, ok := sub.(bson.M)
+					if !ok {
+						continue
+					}
+					if err := sanitizeQuery(sm, prefix+key+"."+strconv.Itoa(i)+".", o); err != nil {
+						return err
+					}
+				}
+			}
+			continue
+		}
+
+		ops, isOps := operatorKeys(value)
+		if !isOps {
+			continue
+		}
+		if err := sanitizeOperators(ops, prefix+key, o); err != nil {
+			if !o.Escape || forbiddenIn(value) {
+				return err
+			}
+			query[key] = bson.M{"$eq": value}
+		}
+	}
+	return nil
+}
+
+// sanitizeOperators 检查字段上的操作符文档，如 {"$gt": 1}
+func sanitizeOperators(ops bson.M, path string, o SanitizeOptions) error {
+	for _, op := range sortByMapKeys(ops) {
+		arg := ops[op]
+		if !o.allowed(op) {
+			return &FilterError{Path: path, Operator: op}
+		}
+		switch op {
+		case "$not":
+			if sub, ok := operatorKeys(arg); ok {
+				if err := sanitizeOperators(sub, path, o); err != nil {
+					return err
+				}
+			}
+		case "$elemMatch":
+			sub, _ := arg.(bson.M)
+			if _, isOps := operatorKeys(sub); isOps {
+				if err := sanitizeOperators(sub, path, o); err != nil {
+					return err
+				}
+			} else if err := sanitizeQuery(sub, path+".", o); err != nil {
+				return err
+			}
+		case "$all":
+			// $all 的元素可以是 {"$elemMatch": ...}
+			items, _ := arg.(bson.A)
+			for _, item := range items {
+				if sub, ok := operatorKeys(item); ok {
+					if err := sanitizeOperators(sub, path, o); err != nil {
+						return err
+					}
+				}
+			}
+		case "$in", "$nin":
+			// 元素按字面值匹配，出现操作符文档说明输入不可信
+			items, _ := arg.(bson.A)
+			for _, item := range items {
+				if sub, ok := operatorKeys(item); ok {
+					for _, k := range sortByMapKeys(sub) {
+						if strings.HasPrefix(k, "$") {
+							return &FilterError{Path: path, Operator: k}
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// operatorKeys 含有 $ 开头的键即视为操作符文档，混入的普通字段按不允许的操作符处理
+func operatorKeys(v interface{}) (bson.M, bool) {
+	m, ok := v.(bson.M)
+	if !ok {
+		return nil, false
+	}
+	for k := range m {
+		if strings.HasPrefix(k, "$") {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// forbiddenIn 值中任意位置是否含有 ForbiddenOperators
+func forbiddenIn(v interface{}) bool {
+	switch x := v.(type) {
+	case bson.M:
+		for k, item := range x {
+			for _, f := range ForbiddenOperators {
+				if k == f {
+					return true
+				}
+			}
+			if forbiddenIn(item) {
+				return true
+			}
+		}
+	case bson.A:
+		for _, item := range x {
+			if forbiddenIn(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type strictFilterKey struct{}
+
+// WithStrictFilter 返回严格模式的 context：本次调用的过滤条件在进入中间件之前经 SanitizeFilter 检查，
+// 不通过时返回 *FilterError。适用于过滤条件来自请求参数的调用；
+// FindAsOf 等在内部构造操作符条件的函数不应使用该 context
+func WithStrictFilter(ctx context.Context, opts ...SanitizeOptions) context.Context {
+	var o SanitizeOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	return context.WithValue(ctx, strictFilterKey{}, o)
+}
+
+// sanitizeOperation 严格模式下检查并替换操作的过滤条件
+func sanitizeOperation(ctx context.Context, op *Operation) error {
+	o, ok := ctx.Value(strictFilterKey{}).(SanitizeOptions)
+	if !ok || op.Filter == nil {
+		return nil
+	}
+	filter, err := SanitizeFilter(op.Filter, o)
+	if err != nil {
+		return err
+	}
+	op.Filter = filter
+	return nil
+}
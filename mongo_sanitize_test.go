@@ -0,0 +1,86 @@
+package mongodb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSanitizeFilter(t *testing.T) {
+	safe := SanitizeOptions{AllowedOperators: SafeOperators}
+	cases := []struct {
+		input string
+		opts  SanitizeOptions
+		path  string
+		op    string
+	}{
+		{input: `{"name": "Alice", "addr": {"city": "Beijing"}}`},
+		{input: `{"password": {"$ne": null}}`, path: "password", op: "$ne"},
+		{input: `{"$where": "sleep(1000)"}`, opts: safe, op: "$where"},
+		{input: `{"$expr": {"$gt": ["$a", "$b"]}}`, opts: SanitizeOptions{AllowedOperators: []string{"$expr"}}, op: "$expr"},
+		{input: `{"age": {"$gte": 18}, "$or": [{"role": "admin"}, {"tags": {"$in": ["dev"]}}]}`, opts: safe},
+		{input: `{"$or": [{"name": "a"}, {"name": {"$regex": ".*"}}]}`, opts: safe, path: "$or.1.name", op: "$regex"},
+		{input: `{"items": {"$elemMatch": {"qty": {"$function": {}}}}}`, opts: safe, path: "items.qty", op: "$function"},
+		{input: `{"age": {"$not": {"$where": "1"}}}`, opts: safe, path: "age", op: "$where"},
+		{input: `{"age": {"$gt": 1, "x": 1}}`, opts: safe, path: "age", op: "x"},
+		{input: `{"tags": {"$all": [{"$elemMatch": {"$regex": "^(a+)+$"}}]}}`, opts: safe, path: "tags", op: "$regex"},
+		{input: `{"tags": {"$all": ["dev", {"$elemMatch": {"$gt": "a"}}]}}`, opts: safe},
+		{input: `{"role": {"$in": ["admin", {"$gt": ""}]}}`, opts: safe, path: "role", op: "$gt"},
+		{input: `{"role": {"$nin": [{"$ne": null}]}}`, opts: safe, path: "role", op: "$ne"},
+	}
+	for _, c := range cases {
+		var filter map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(c.input), &filter))
+		_, err := SanitizeFilter(filter, c.opts)
+		if c.op == "" {
+			assert.NoError(t, err, c.input)
+			continue
+		}
+		var fe *FilterError
+		if assert.True(t, errors.As(err, &fe), c.input) {
+			assert.Equal(t, c.path, fe.Path, c.input)
+			assert.Equal(t, c.op, fe.Operator, c.input)
+		}
+		assert.True(t, errors.Is(err, ErrUnsafeFilter))
+	}
+
+	// 转义为字面值匹配
+	escape := SanitizeOptions{AllowedOperators: []string{"$in"}, Escape: true}
+	filter, err := SanitizeFilter(bson.M{"password": bson.M{"$ne": nil}, "role": bson.M{"$in": bson.A{"admin"}}}, escape)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{
+		"password": bson.M{"$eq": bson.M{"$ne": nil}},
+		"role":     bson.M{"$in": bson.A{"admin"}},
+	}, filter)
+	_, err = SanitizeFilter(bson.M{"$where": "1"}, escape)
+	assert.True(t, errors.Is(err, ErrUnsafeFilter))
+	_, err = SanitizeFilter(bson.M{"a": bson.M{"$not": bson.M{"$where": "1"}}}, escape)
+	assert.True(t, errors.Is(err, ErrUnsafeFilter))
+}
+
+func TestStrictFilter(t *testing.T) {
+	m := useTestMemoryBackend(t)
+	assert.NoError(t, m.Seed("user",
+		bson.M{"_id": 1, "name": "Alice", "password": "secret"},
+		bson.M{"_id": 2, "name": "Bob", "password": bson.M{"$ne": nil}},
+	))
+
+	var input map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"password": {"$ne": null}}`), &input))
+
+	_, err := FindOneWithContext(WithStrictFilter(context.Background()), "user", input)
+	assert.True(t, errors.Is(err, ErrUnsafeFilter))
+	docs, err := FindListWithContext(WithStrictFilter(context.Background(), SanitizeOptions{Escape: true}), "user", input)
+	assert.NoError(t, err)
+	if assert.Len(t, docs, 1) {
+		assert.Equal(t, "Bob", docs[0]["name"])
+	}
+	// 不使用严格模式时照常执行
+	n, err := CountWithContext(context.Background(), "user", input)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+}
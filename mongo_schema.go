@@ -0,0 +1,352 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// SchemaOptions 集合校验器的配置
+type SchemaOptions struct {
+	// ValidationLevel 为 off、strict 或 moderate，为空时使用 strict
+	ValidationLevel string
+	// ValidationAction 为 error 或 warn，为空时使用 error
+	ValidationAction string
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	objectIDType   = reflect.TypeOf(primitive.ObjectID{})
+	dateTimeType   = reflect.TypeOf(primitive.DateTime(0))
+	decimalType    = reflect.TypeOf(primitive.Decimal128{})
+	timestampType  = reflect.TypeOf(primitive.Timestamp{})
+	binaryType     = reflect.TypeOf(primitive.Binary{})
+	bsonDType      = reflect.TypeOf(bson.D{})
+	bsonMType      = reflect.TypeOf(bson.M{})
+	bytesType      = reflect.TypeOf([]byte(nil))
+	emptyIfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// GenerateJSONSchema 根据结构体生成 $jsonSchema：字段名取 bson 标签，
+// validate 标签中的 required 对应 required，oneof 对应 enum，min/max 对应长度或取值范围，
+// 指针、切片、map 和 bson.M 字段允许为 null，非必填字段的其它规则允许空值，嵌套结构体和数组递归生成
+func GenerateJSONSchema(v interface{}) (bson.M, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("GenerateJSONSchema 需要结构体，实际为 %v", t)
+	}
+	return schemaForStruct(t, map[reflect.Type]bool{})
+}
+
+func schemaForStruct(t reflect.Type, visiting map[reflect.Type]bool) (bson.M, error) {
+	if visiting[t] {
+		// 递归引用的结构体不再展开
+		return bson.M{"bsonType": "object"}, nil
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	properties := bson.M{}
+	var required []string
+	if err := collectProperties(t, properties, &required, visiting); err != nil {
+		return nil, err
+	}
+	schema := bson.M{"bsonType": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema, nil
+}
+
+func collectProperties(t reflect.Type, properties bson.M, required *[]string, visiting map[reflect.Type]bool) error {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, inline, skip := bsonFieldName(sf)
+		if skip {
+			continue
+		}
+		if inline {
+			ft := sf.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := collectProperties(ft, properties, required, visiting); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		prop, err := schemaForType(sf.Type, visiting)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		rules := parseValidateTag(sf.Tag.Get("validate"))
+		applyValidateRules(prop, rules)
+		properties[name] = prop
+		if _, ok := rules["required"]; ok {
+			*required = append(*required, name)
+		}
+	}
+	return nil
+}
+
+func schemaForType(t reflect.Type, visiting map[reflect.Type]bool) (bson.M, error) {
+	if t.Kind() == reflect.Ptr {
+		s, err := schemaForType(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		if bt, ok := s["bsonType"]; ok {
+			s["bsonType"] = appendNull(bt)
+		}
+		return s, nil
+	}
+
+	switch t {
+	case timeType, dateTimeType:
+		return bson.M{"bsonType": "date"}, nil
+	case objectIDType:
+		return bson.M{"bsonType": "objectId"}, nil
+	case decimalType:
+		return bson.M{"bsonType": "decimal"}, nil
+	case timestampType:
+		return bson.M{"bsonType": "timestamp"}, nil
+	case binaryType, bytesType:
+		return bson.M{"bsonType": "binData"}, nil
+	case bsonDType, bsonMType:
+		// nil 编码为 null
+		return bson.M{"bsonType": bson.A{"object", "null"}}, nil
+	case emptyIfaceType:
+		return bson.M{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return bson.M{"bsonType": "string"}, nil
+	case reflect.Bool:
+		return bson.M{"bsonType": "bool"}, nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return bson.M{"bsonType": "int"}, nil
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		// 驱动会把能放进 int32 的值编码为 int
+		return bson.M{"bsonType": bson.A{"int", "long"}}, nil
+	case reflect.Float32, reflect.Float64:
+		return bson.M{"bsonType": "double"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := schemaForType(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		s := bson.M{"bsonType": "array"}
+		if len(items) > 0 {
+			s["items"] = items
+		}
+		if t.Kind() == reflect.Slice {
+			s["bsonType"] = bson.A{"array", "null"}
+		}
+		return s, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, errors.New("map 的键必须是字符串")
+		}
+		values, err := schemaForType(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		s := bson.M{"bsonType": bson.A{"object", "null"}}
+		if len(values) > 0 {
+			s["additionalProperties"] = values
+		}
+		return s, nil
+	case reflect.Struct:
+		return schemaForStruct(t, visiting)
+	case reflect.Interface:
+		return bson.M{}, nil
+	}
+	return nil, fmt.Errorf("不支持的类型 %s", t)
+}
+
+func appendNull(bt interface{}) interface{} {
+	switch v := bt.(type) {
+	case string:
+		return bson.A{v, "null"}
+	case bson.A:
+		for _, item := range v {
+			if item == "null" {
+				return v
+			}
+		}
+		return append(v, "null")
+	}
+	return bt
+}
+
+func parseValidateTag(tag string) map[string]string {
+	rules := map[string]string{}
+	if tag == "" || tag == "-" {
+		return rules
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name != "" {
+			rules[name] = param
+		}
+	}
+	return rules
+}
+
+// applyValidateRules 将 validate 标签转为对应的 schema 约束。
+// 与 ValidateStruct 一致，没有 required 的字段为空值时不检查其它规则
+func applyValidateRules(prop bson.M, rules map[string]string) {
+	kind := schemaKind(prop["bsonType"])
+	c := bson.M{}
+	if opts, ok := rules["oneof"]; ok {
+		var enum bson.A
+		for _, o := range strings.Fields(opts) {
+			if kind == "number" {
+				if n, err := strconv.ParseFloat(o, 64); err == nil {
+					enum = append(enum, n)
+					continue
+				}
+			}
+			enum = append(enum, o)
+		}
+		c["enum"] = enum
+	}
+	if _, ok := rules["email"]; ok && kind == "string" {
+		c["pattern"] = emailRegexp.String()
+	}
+	for _, r := range []string{"min", "max", "len"} {
+		p, ok := rules[r]
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			continue
+		}
+		switch kind {
+		case "string":
+			setBound(c, r, "minLength", "maxLength", int64(n))
+		case "array":
+			setBound(c, r, "minItems", "maxItems", int64(n))
+		case "number":
+			setBound(c, r, "minimum", "maximum", n)
+		}
+	}
+	if len(c) == 0 {
+		return
+	}
+
+	_, required := rules["required"]
+	empty := emptySchema(kind)
+	if required || empty == nil {
+		for k, v := range c {
+			prop[k] = v
+		}
+		return
+	}
+	prop["anyOf"] = bson.A{c, empty}
+}
+
+// emptySchema 匹配空值（零值或 null）的约束，约束只作用于对应类型，其它类型自然通过
+func emptySchema(kind string) bson.M {
+	switch kind {
+	case "string":
+		return bson.M{"maxLength": int64(0)}
+	case "array":
+		return bson.M{"maxItems": int64(0)}
+	case "number":
+		return bson.M{"enum": bson.A{0, nil}}
+	}
+	return nil
+}
+
+func setBound(prop bson.M, rule, minKey, maxKey string, v interface{}) {
+	switch rule {
+	case "min":
+		prop[minKey] = v
+	case "max":
+		prop[maxKey] = v
+	case "len":
+		prop[minKey] = v
+		prop[maxKey] = v
+	}
+}
+
+func schemaKind(bt interface{}) string {
+	types := []interface{}{bt}
+	if a, ok := bt.(bson.A); ok {
+		types = a
+	}
+	for _, t := range types {
+		switch t {
+		case "string":
+			return "string"
+		case "array":
+			return "array"
+		case "int", "long", "double", "decimal":
+			return "number"
+		}
+	}
+	return ""
+}
+
+// ApplyJSONSchema 为集合设置 $jsonSchema 校验器，集合已存在时执行 collMod，否则执行 create
+func ApplyJSONSchema(ctx context.Context, collectionName string, schema bson.M, opts SchemaOptions) error {
+	level := opts.ValidationLevel
+	if level == "" {
+		level = "strict"
+	}
+	action := opts.ValidationAction
+	if action == "" {
+		action = "error"
+	}
+	validator := bson.M{"$jsonSchema": schema}
+
+	ctx, cancel := context.WithTimeout(ctx, timeoutSec)
+	defer cancel()
+	db := GetDatabase()
+	err := db.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: collectionName},
+		{Key: "validator", Value: validator},
+		{Key: "validationLevel", Value: level},
+		{Key: "validationAction", Value: action},
+	}).Err()
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == 26 {
+		// NamespaceNotFound
+		return db.RunCommand(ctx, bson.D{
+			{Key: "create", Value: collectionName},
+			{Key: "validator", Value: validator},
+			{Key: "validationLevel", Value: level},
+			{Key: "validationAction", Value: action},
+		}).Err()
+	}
+	return err
+}
+
+// ApplyStructSchema 根据结构体生成 $jsonSchema 并应用到集合
+func ApplyStructSchema(ctx context.Context, collectionName string, v interface{}, opts SchemaOptions) error {
+	schema, err := GenerateJSONSchema(v)
+	if err != nil {
+		return err
+	}
+	return ApplyJSONSchema(ctx, collectionName, schema, opts)
+}
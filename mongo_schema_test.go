@@ -0,0 +1,67 @@
+package mongodb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type schemaBase struct {
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+type schemaAddress struct {
+	City string `bson:"city" validate:"required"`
+}
+
+type schemaUser struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty"`
+	Name     string             `bson:"name" validate:"required,min=2,max=20"`
+	Age      int                `bson:"age" validate:"min=0"`
+	Role     string             `bson:"role" validate:"oneof=admin user"`
+	Score    float64            `bson:"score"`
+	Tags     []string           `bson:"tags" validate:"max=5"`
+	Address  *schemaAddress     `bson:"address"`
+	Extra    bson.M             `bson:"extra"`
+	Ignored  string             `bson:"-"`
+	Base     schemaBase         `bson:",inline"`
+	internal string
+}
+
+func TestGenerateJSONSchema(t *testing.T) {
+	schema, err := GenerateJSONSchema(&schemaUser{})
+	assert.NoError(t, err)
+	assert.Equal(t, "object", schema["bsonType"])
+	assert.ElementsMatch(t, []string{"name"}, schema["required"])
+
+	props := schema["properties"].(bson.M)
+	assert.Equal(t, bson.M{"bsonType": "objectId"}, props["_id"])
+	assert.Equal(t, bson.M{"bsonType": "string", "minLength": int64(2), "maxLength": int64(20)}, props["name"])
+	// 非必填字段允许空值，与 ValidateStruct 一致
+	assert.Equal(t, bson.M{"bsonType": bson.A{"int", "long"}, "anyOf": bson.A{
+		bson.M{"minimum": float64(0)}, bson.M{"enum": bson.A{0, nil}},
+	}}, props["age"])
+	assert.Equal(t, bson.M{"bsonType": "string", "anyOf": bson.A{
+		bson.M{"enum": bson.A{"admin", "user"}}, bson.M{"maxLength": int64(0)},
+	}}, props["role"])
+	assert.NoError(t, ValidateStruct(&schemaUser{Name: "Alice"}))
+	assert.Equal(t, bson.M{"bsonType": "double"}, props["score"])
+	assert.Equal(t, bson.M{"bsonType": bson.A{"array", "null"}, "items": bson.M{"bsonType": "string"}, "anyOf": bson.A{
+		bson.M{"maxItems": int64(5)}, bson.M{"maxItems": int64(0)},
+	}}, props["tags"])
+	assert.Equal(t, bson.M{
+		"bsonType":   bson.A{"object", "null"},
+		"properties": bson.M{"city": bson.M{"bsonType": "string"}},
+		"required":   []string{"city"},
+	}, props["address"])
+	assert.Equal(t, bson.M{"bsonType": bson.A{"object", "null"}}, props["extra"])
+	assert.Equal(t, bson.M{"bsonType": "date"}, props["created_at"])
+	assert.NotContains(t, props, "Ignored")
+	assert.NotContains(t, props, "internal")
+
+	_, err = GenerateJSONSchema("not a struct")
+	assert.Error(t, err)
+}
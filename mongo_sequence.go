@@ -0,0 +1,153 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultCountersCollection 保存序列号的默认集合
+const DefaultCountersCollection = "counters"
+
+var (
+	countersMu         sync.RWMutex
+	countersCollection = DefaultCountersCollection
+)
+
+// SetCountersCollection 设置保存序列号的集合
+func SetCountersCollection(collectionName string) {
+	countersMu.Lock()
+	defer countersMu.Unlock()
+	countersCollection = collectionName
+}
+
+func getCountersCollection() string {
+	countersMu.RLock()
+	defer countersMu.RUnlock()
+	return countersCollection
+}
+
+// NextSequence 返回序列 name 的下一个值，从 1 开始
+func NextSequence(name string) (int64, error) {
+	return NextSequenceWithContext(context.Background(), name)
+}
+
+// NextSequenceWithContext 同 NextSequence
+func NextSequenceWithContext(ctx context.Context, name string) (int64, error) {
+	_, end, err := NextSequenceRangeWithContext(ctx, name, 1)
+	return end, err
+}
+
+// NextSequenceRange 一次预留 n 个连续的值，返回闭区间 [start, end]
+func NextSequenceRange(name string, n int64) (start, end int64, err error) {
+	return NextSequenceRangeWithContext(context.Background(), name, n)
+}
+
+// NextSequenceRangeWithContext 同 NextSequenceRange，
+// 通过 counters 集合上的 findOneAndUpdate + $inc 保证多实例间不重复
+func NextSequenceRangeWithContext(ctx context.Context, name string, n int64) (start, end int64, err error) {
+	if n < 1 {
+		return 0, 0, fmt.Errorf("序列 %s 预留数量必须大于 0", name)
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	doc, err := FindOneAndUpdateWithContext(ctx, getCountersCollection(), bson.M{"_id": name}, nil,
+		bson.D{{Key: "$inc", Value: bson.M{"seq": n}}}, opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	end = toInt64(doc["seq"])
+	return end - n + 1, end, nil
+}
+
+// SequenceFormat 序列号的展示格式，如 ORD-20261018-000123
+type SequenceFormat struct {
+	Prefix string
+	// DateLayout 日期部分的格式，为空时不含日期，如 "20060102"
+	DateLayout string
+	// Width 数字部分的最小宽度，不足时补 0
+	Width int
+	// Separator 各部分之间的分隔符，为空时使用 "-"
+	Separator string
+	// PerDate 为 true 时每个日期单独计数，即每天从 1 开始
+	PerDate bool
+}
+
+// Format 按格式拼接序列号
+func (f SequenceFormat) Format(t time.Time, seq int64) string {
+	sep := f.Separator
+	if sep == "" {
+		sep = "-"
+	}
+	var parts []string
+	if f.Prefix != "" {
+		parts = append(parts, f.Prefix)
+	}
+	if f.DateLayout != "" {
+		parts = append(parts, t.Format(f.DateLayout))
+	}
+	parts = append(parts, fmt.Sprintf("%0*d", f.Width, seq))
+	return strings.Join(parts, sep)
+}
+
+// counterName PerDate 时在序列名后追加日期
+func (f SequenceFormat) counterName(name string, t time.Time) string {
+	if f.PerDate && f.DateLayout != "" {
+		return name + ":" + t.Format(f.DateLayout)
+	}
+	return name
+}
+
+// NextSequenceString 返回格式化后的下一个序列号
+func NextSequenceString(name string, f SequenceFormat) (string, error) {
+	return NextSequenceStringWithContext(context.Background(), name, f)
+}
+
+// NextSequenceStringWithContext 同 NextSequenceString
+func NextSequenceStringWithContext(ctx context.Context, name string, f SequenceFormat) (string, error) {
+	now := time.Now()
+	seq, err := NextSequenceWithContext(ctx, f.counterName(name, now))
+	if err != nil {
+		return "", err
+	}
+	return f.Format(now, seq), nil
+}
+
+// SequenceAllocator 进程内的号段分配器，每次从数据库预留 blockSize 个值，用完再取，
+// 减少往返次数。进程退出时未用完的值会被跳过，因此序列可能不连续
+type SequenceAllocator struct {
+	name      string
+	blockSize int64
+
+	mu   sync.Mutex
+	next int64
+	end  int64
+}
+
+// NewSequenceAllocator 创建号段分配器，blockSize 小于 1 时按 1 处理
+func NewSequenceAllocator(name string, blockSize int64) *SequenceAllocator {
+	if blockSize < 1 {
+		blockSize = 1
+	}
+	return &SequenceAllocator{name: name, blockSize: blockSize}
+}
+
+// Next 返回下一个值
+func (a *SequenceAllocator) Next(ctx context.Context) (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.next == 0 || a.next > a.end {
+		start, end, err := NextSequenceRangeWithContext(ctx, a.name, a.blockSize)
+		if err != nil {
+			return 0, err
+		}
+		a.next, a.end = start, end
+	}
+	v := a.next
+	a.next++
+	return v, nil
+}
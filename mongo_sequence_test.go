@@ -0,0 +1,64 @@
+package mongodb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// stubCounters 用中间件模拟 counters 集合
+func stubCounters() *int {
+	calls := 0
+	counters := map[interface{}]int64{}
+	Use(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) (interface{}, error) {
+			calls++
+			id := op.Filter.(bson.M)["_id"]
+			inc := op.Operators[0].Value.(bson.M)["seq"].(int64)
+			counters[id] += inc
+			return bson.M{"_id": id, "seq": counters[id]}, nil
+		}
+	})
+	return &calls
+}
+
+func TestNextSequence(t *testing.T) {
+	defer ResetMiddlewares()
+	stubCounters()
+
+	v, err := NextSequence("order")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), v)
+
+	start, end, err := NextSequenceRange("order", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), start)
+	assert.Equal(t, int64(11), end)
+
+	_, _, err = NextSequenceRange("order", 0)
+	assert.Error(t, err)
+
+	f := SequenceFormat{Prefix: "ORD", DateLayout: "20060102", Width: 6, PerDate: true}
+	assert.Equal(t, "ORD-20261018-000123", f.Format(time.Date(2026, 10, 18, 0, 0, 0, 0, time.Local), 123))
+	s, err := NextSequenceString("order", f)
+	assert.NoError(t, err)
+	assert.Equal(t, "ORD-"+time.Now().Format("20060102")+"-000001", s)
+}
+
+func TestSequenceAllocator(t *testing.T) {
+	defer ResetMiddlewares()
+	calls := stubCounters()
+
+	a := NewSequenceAllocator("invoice", 3)
+	var got []int64
+	for i := 0; i < 7; i++ {
+		v, err := a.Next(context.Background())
+		assert.NoError(t, err)
+		got = append(got, v)
+	}
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7}, got)
+	assert.Equal(t, 3, *calls)
+}
@@ -0,0 +1,263 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SlowQueryEvent 慢操作事件
+type SlowQueryEvent struct {
+	Collection string
+	Op         string
+	// FilterShape 过滤条件的结构，值已替换为类型占位符，如 {"age": {"$gt": "<int>"}}
+	FilterShape interface{}
+	Duration    time.Duration
+	Threshold   time.Duration
+	// Caller 调用方位置，格式为 file:line
+	Caller   string
+	Function string
+	// Plan 开启 Explain 时附带的 winningPlan
+	Plan       bson.M
+	ExplainErr error
+	Err        error
+}
+
+// SlowQueryOptions 慢操作检测配置
+type SlowQueryOptions struct {
+	// Threshold 超过该耗时视为慢操作，<=0 表示关闭
+	Threshold time.Duration
+	// Explain 为 true 时对慢的查询/计数/更新执行 explain 并附带 winningPlan，会额外增加一次往返
+	Explain bool
+	// Handler 事件处理函数，为 nil 时以 Warn 级别输出到日志
+	Handler func(SlowQueryEvent)
+}
+
+var (
+	slowMu      sync.RWMutex
+	slowOptions SlowQueryOptions
+)
+
+// SetSlowQueryOptions 设置慢操作检测配置
+func SetSlowQueryOptions(o SlowQueryOptions) {
+	slowMu.Lock()
+	defer slowMu.Unlock()
+	slowOptions = o
+}
+
+// SetSlowThreshold 只设置慢操作阈值，<=0 表示关闭
+func SetSlowThreshold(d time.Duration) {
+	slowMu.Lock()
+	defer slowMu.Unlock()
+	slowOptions.Threshold = d
+}
+
+// GetSlowQueryOptions 返回当前慢操作检测配置
+func GetSlowQueryOptions() SlowQueryOptions {
+	slowMu.RLock()
+	defer slowMu.RUnlock()
+	return slowOptions
+}
+
+// checkSlow 在操作结束后检测是否超过阈值
+func (r *opRecord) checkSlow() {
+	o := GetSlowQueryOptions()
+	if o.Threshold <= 0 || r.Duration < o.Threshold {
+		return
+	}
+
+	ev := SlowQueryEvent{
+		Collection:  r.Collection,
+		Op:          r.Op,
+		FilterShape: FilterShape(r.Filter),
+		Duration:    r.Duration,
+		Threshold:   o.Threshold,
+		Caller:      r.Caller,
+		Function:    r.Function,
+		Err:         r.Err,
+	}
+	if o.Explain {
+		ev.Plan, ev.ExplainErr = explainOp(r)
+	}
+
+	if o.Handler != nil {
+		o.Handler(ev)
+		return
+	}
+	fields := []Field{
+		{Key: "collection", Value: ev.Collection},
+		{Key: "op", Value: ev.Op},
+		{Key: "duration", Value: ev.Duration},
+		{Key: "threshold", Value: ev.Threshold},
+		{Key: "filter_shape", Value: ev.FilterShape},
+		{Key: "caller", Value: ev.Caller},
+	}
+	if ev.Plan != nil {
+		fields = append(fields, Field{Key: "plan", Value: ev.Plan})
+	}
+	if ev.ExplainErr != nil {
+		fields = append(fields, Field{Key: "explain_error", Value: ev.ExplainErr.Error()})
+	}
+	logf(LevelWarn, "mongo slow operation", fields...)
+}
+
+// callerOutsidePackage 返回调用栈中第一个不属于本包的位置
+func callerOutsidePackage() (file string, line int, function string) {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	self := reflect.TypeOf(opRecord{}).PkgPath() + "."
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, self) || strings.HasSuffix(f.File, "_test.go") {
+			return f.File, f.Line, f.Function
+		}
+		if !more {
+			return f.File, f.Line, f.Function
+		}
+	}
+}
+
+// FilterShape 返回过滤条件的结构，所有值替换为类型占位符，用于日志和聚合慢查询
+func FilterShape(filter interface{}) interface{} {
+	switch v := filter.(type) {
+	case nil:
+		return nil
+	case bson.M:
+		out := bson.M{}
+		for k, item := range v {
+			out[k] = FilterShape(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := bson.M{}
+		for k, item := range v {
+			out[k] = FilterShape(item)
+		}
+		return out
+	case bson.D:
+		out := make(bson.D, 0, len(v))
+		for _, e := range v {
+			out = append(out, bson.E{Key: e.Key, Value: FilterShape(e.Value)})
+		}
+		return out
+	case bson.A:
+		return shapeSlice(v)
+	case []interface{}:
+		return shapeSlice(v)
+	case primitive.Regex:
+		return "<regex>"
+	case primitive.ObjectID:
+		return "<objectId>"
+	case primitive.DateTime, time.Time:
+		return "<date>"
+	default:
+		rv := reflect.ValueOf(filter)
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Array:
+			items := make([]interface{}, rv.Len())
+			for i := range items {
+				items[i] = rv.Index(i).Interface()
+			}
+			return shapeSlice(items)
+		case reflect.Struct:
+			if m, err := Struct2BsonM(filter); err == nil {
+				return FilterShape(m)
+			}
+		}
+		return "<" + rv.Type().String() + ">"
+	}
+}
+
+// shapeSlice 数组中相同结构的元素只保留一个
+func shapeSlice(items []interface{}) bson.A {
+	out := bson.A{}
+	seen := map[string]struct{}{}
+	for _, item := range items {
+		s := FilterShape(item)
+		key := fmt.Sprint(s)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, s)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return fmt.Sprint(out[i]) < fmt.Sprint(out[j]) })
+	return out
+}
+
+// explainOp 对慢操作执行 explain，返回 queryPlanner.winningPlan。
+// 只支持 MongoDB 后端，内存后端或尚未 Connect 时返回错误
+func explainOp(r *opRecord) (bson.M, error) {
+	cmd, err := explainCommand(r)
+	if err != nil {
+		return nil, err
+	}
+	client := GetInstance().GetClient()
+	if _, ok := GetBackend().(mongoBackend); !ok || client == nil {
+		return nil, fmt.Errorf("explain 需要已连接的 MongoDB 后端")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutSec)
+	defer cancel()
+	var out bson.M
+	// 与操作使用同一个数据库，如按数据库隔离时租户的数据库
+	err = client.Database(r.databaseName()).RunCommand(ctx, bson.D{
+		{Key: "explain", Value: cmd},
+		{Key: "verbosity", Value: "queryPlanner"},
+	}).Decode(&out)
+	if err != nil {
+		return nil, err
+	}
+	if planner, ok := out["queryPlanner"].(bson.M); ok {
+		if plan, ok := planner["winningPlan"].(bson.M); ok {
+			return plan, nil
+		}
+	}
+	return nil, fmt.Errorf("explain 结果中没有 winningPlan")
+}
+
+// explainCommand 返回与操作对应的命令，更新文档与实际执行时相同
+func explainCommand(r *opRecord) (bson.D, error) {
+	filter := r.Filter
+	if filter == nil {
+		filter = bson.D{}
+	}
+	switch r.Op {
+	case "FindOne":
+		return bson.D{{Key: "find", Value: r.Collection}, {Key: "filter", Value: filter}, {Key: "limit", Value: 1}}, nil
+	case "FindList":
+		return bson.D{{Key: "find", Value: r.Collection}, {Key: "filter", Value: filter}}, nil
+	case "Count":
+		return bson.D{{Key: "count", Value: r.Collection}, {Key: "query", Value: filter}}, nil
+	case "UpdateOne", "UpdateMany", "softDelete":
+		return bson.D{{Key: "update", Value: r.Collection}, {Key: "updates", Value: bson.A{bson.D{
+			{Key: "q", Value: filter},
+			{Key: "u", Value: updateDocument(r.Document, r.Operators)},
+			{Key: "multi", Value: r.Op == "UpdateMany"},
+		}}}}, nil
+	case "ReplaceOne":
+		return bson.D{{Key: "update", Value: r.Collection}, {Key: "updates", Value: bson.A{bson.D{
+			{Key: "q", Value: filter},
+			{Key: "u", Value: r.Document},
+		}}}}, nil
+	case "DeleteOne", "DeleteMany":
+		limit := 0
+		if r.Op == "DeleteOne" {
+			limit = 1
+		}
+		return bson.D{{Key: "delete", Value: r.Collection}, {Key: "deletes", Value: bson.A{bson.D{
+			{Key: "q", Value: filter},
+			{Key: "limit", Value: limit},
+		}}}}, nil
+	default:
+		return nil, fmt.Errorf("explain 不支持的操作: %s", r.Op)
+	}
+}
@@ -0,0 +1,93 @@
+package mongodb
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestFilterShape(t *testing.T) {
+	filter := bson.M{
+		"_id":    primitive.NewObjectID(),
+		"name":   "Alice",
+		"age":    bson.M{"$gt": 18},
+		"status": bson.M{"$in": bson.A{"a", "b", 1}},
+		"$or":    bson.A{bson.D{{Key: "deleted", Value: false}}},
+	}
+	want := bson.M{
+		"_id":    "<objectId>",
+		"name":   "<string>",
+		"age":    bson.M{"$gt": "<int>"},
+		"status": bson.M{"$in": bson.A{"<int>", "<string>"}},
+		"$or":    bson.A{bson.D{{Key: "deleted", Value: "<bool>"}}},
+	}
+	assert.Equal(t, want, FilterShape(filter))
+	assert.Nil(t, FilterShape(nil))
+}
+
+func TestCheckSlow(t *testing.T) {
+	var events []SlowQueryEvent
+	SetSlowQueryOptions(SlowQueryOptions{
+		Threshold: time.Millisecond,
+		Handler:   func(ev SlowQueryEvent) { events = append(events, ev) },
+	})
+	defer SetSlowQueryOptions(SlowQueryOptions{})
+
+	rec, _ := startOp(context.Background(), "user", "FindOne", bson.M{"name": "Alice"}, nil)
+	rec.finish(nil)
+	assert.Len(t, events, 0)
+
+	rec, _ = startOp(context.Background(), "user", "FindOne", bson.M{"name": "Alice"}, nil)
+	rec.Start = rec.Start.Add(-time.Second)
+	rec.finish(nil)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "user", events[0].Collection)
+		assert.Equal(t, bson.M{"name": "<string>"}, events[0].FilterShape)
+		assert.True(t, strings.HasSuffix(events[0].Function, "TestCheckSlow"), events[0].Function)
+		assert.Contains(t, events[0].Caller, "mongo_slow_test.go:")
+	}
+
+	// explain 使用操作所在的数据库
+	rec, _ = startOp(WithDatabase(context.Background(), "app_acme"), "user", "FindOne", bson.M{"name": "Alice"}, nil)
+	assert.Equal(t, "app_acme", rec.Database)
+}
+
+func TestExplainWithoutClient(t *testing.T) {
+	useTestMemoryBackend(t)
+	var events []SlowQueryEvent
+	SetSlowQueryOptions(SlowQueryOptions{
+		Threshold: time.Nanosecond,
+		Explain:   true,
+		Handler:   func(ev SlowQueryEvent) { events = append(events, ev) },
+	})
+	defer SetSlowQueryOptions(SlowQueryOptions{})
+
+	// 内存后端不执行 explain，错误只记录在事件中
+	_, err := FindListWithContext(context.Background(), "user", bson.M{})
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, events) {
+		assert.Error(t, events[0].ExplainErr)
+		assert.Nil(t, events[0].Plan)
+	}
+}
+
+func TestExplainCommand(t *testing.T) {
+	// 只有操作符的更新与实际执行时一致，不加入 $set
+	rec := &opRecord{Collection: "user", Op: "UpdateOne", Filter: bson.M{"_id": 1}, Operators: bson.D{{Key: "$inc", Value: bson.M{"n": 1}}}}
+	cmd, err := explainCommand(rec)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "$inc", Value: bson.M{"n": 1}}}, cmd[1].Value.(bson.A)[0].(bson.D)[1].Value)
+
+	rec.Document = bson.M{"name": "Alice"}
+	cmd, _ = explainCommand(rec)
+	assert.Equal(t, bson.D{{Key: "$set", Value: bson.M{"name": "Alice"}}, {Key: "$inc", Value: bson.M{"n": 1}}},
+		cmd[1].Value.(bson.A)[0].(bson.D)[1].Value)
+
+	_, err = explainCommand(&opRecord{Op: "InsertOne"})
+	assert.Error(t, err)
+}
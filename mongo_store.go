@@ -0,0 +1,77 @@
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Store 包级 CRUD 函数的接口形式，业务代码依赖 Store 而不是直接调用包级函数，
+// 测试时可以注入 MockStore。方法与对应的 XxxWithContext 函数一致
+type Store interface {
+	FindOne(ctx context.Context, collectionName string, filter interface{}, opts ...*options.FindOneOptions) (bson.M, error)
+	FindList(ctx context.Context, collectionName string, filter interface{}, opts ...*options.FindOptions) ([]bson.M, error)
+	Count(ctx context.Context, collectionName string, filter interface{}, opts ...*options.CountOptions) (int64, error)
+	InsertOne(ctx context.Context, collectionName string, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error)
+	Update(ctx context.Context, collectionName, updateType string, filter interface{}, document interface{}, opts ...interface{}) (*mongo.UpdateResult, error)
+	Delete(ctx context.Context, collectionName, deleteType string, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
+	FindOneAndUpdate(ctx context.Context, collectionName string, filter interface{}, document interface{}, operators bson.D, opts ...*options.FindOneAndUpdateOptions) (bson.M, error)
+	Upsert(ctx context.Context, collectionName string, filter interface{}, document interface{}, defaults bson.M) (*UpsertResult, error)
+	Patch(ctx context.Context, collectionName, updateType string, filter interface{}, document interface{}, opts ...interface{}) (*mongo.UpdateResult, error)
+	ApplyMergePatch(ctx context.Context, collectionName string, filter interface{}, patch []byte) (*mongo.UpdateResult, error)
+	ApplyJSONPatch(ctx context.Context, collectionName string, filter interface{}, patch []byte) (*mongo.UpdateResult, error)
+}
+
+// defaultStore 调用包级函数，经过中间件、钩子和当前的 Backend
+type defaultStore struct{}
+
+// DefaultStore 返回调用包级函数的 Store
+func DefaultStore() Store {
+	return defaultStore{}
+}
+
+func (defaultStore) FindOne(ctx context.Context, collectionName string, filter interface{}, opts ...*options.FindOneOptions) (bson.M, error) {
+	return FindOneWithContext(ctx, collectionName, filter, opts...)
+}
+
+func (defaultStore) FindList(ctx context.Context, collectionName string, filter interface{}, opts ...*options.FindOptions) ([]bson.M, error) {
+	return FindListWithContext(ctx, collectionName, filter, opts...)
+}
+
+func (defaultStore) Count(ctx context.Context, collectionName string, filter interface{}, opts ...*options.CountOptions) (int64, error) {
+	return CountWithContext(ctx, collectionName, filter, opts...)
+}
+
+func (defaultStore) InsertOne(ctx context.Context, collectionName string, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	return InsertOneWithContext(ctx, collectionName, document, opts...)
+}
+
+func (defaultStore) Update(ctx context.Context, collectionName, updateType string, filter interface{}, document interface{}, opts ...interface{}) (*mongo.UpdateResult, error) {
+	return UpdateWithContext(ctx, collectionName, updateType, filter, document, opts...)
+}
+
+func (defaultStore) Delete(ctx context.Context, collectionName, deleteType string, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	return DeleteWithContext(ctx, collectionName, deleteType, filter, opts...)
+}
+
+func (defaultStore) FindOneAndUpdate(ctx context.Context, collectionName string, filter interface{}, document interface{}, operators bson.D, opts ...*options.FindOneAndUpdateOptions) (bson.M, error) {
+	return FindOneAndUpdateWithContext(ctx, collectionName, filter, document, operators, opts...)
+}
+
+func (defaultStore) Upsert(ctx context.Context, collectionName string, filter interface{}, document interface{}, defaults bson.M) (*UpsertResult, error) {
+	return UpsertWithContext(ctx, collectionName, filter, document, defaults)
+}
+
+func (defaultStore) Patch(ctx context.Context, collectionName, updateType string, filter interface{}, document interface{}, opts ...interface{}) (*mongo.UpdateResult, error) {
+	return PatchWithContext(ctx, collectionName, updateType, filter, document, opts...)
+}
+
+func (defaultStore) ApplyMergePatch(ctx context.Context, collectionName string, filter interface{}, patch []byte) (*mongo.UpdateResult, error) {
+	return ApplyMergePatchWithContext(ctx, collectionName, filter, patch)
+}
+
+func (defaultStore) ApplyJSONPatch(ctx context.Context, collectionName string, filter interface{}, patch []byte) (*mongo.UpdateResult, error) {
+	return ApplyJSONPatchWithContext(ctx, collectionName, filter, patch)
+}
@@ -0,0 +1,319 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrUnexpectedCall MockStore 收到没有预设的调用
+var ErrUnexpectedCall = errors.New("mongodb: unexpected call to MockStore")
+
+// TestReporter *testing.T 的子集，避免在非测试代码中引入 testing 包
+type TestReporter interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// anyArg 见 AnyArg
+type anyArg struct{}
+
+// AnyArg 作为 OnXxx 的参数时匹配任意值
+var AnyArg interface{} = anyArg{}
+
+// ArgMatcher 作为 OnXxx 的参数时用函数判断是否匹配
+type ArgMatcher func(v interface{}) bool
+
+// StoreCall MockStore 记录的一次调用，Args 为除 ctx 和 opts 以外的参数
+type StoreCall struct {
+	Method     string
+	Collection string
+	Args       []interface{}
+	Options    []interface{}
+}
+
+// MockStore 记录调用并返回预设结果的 Store。
+// 用 OnXxx 预设调用，默认每个预设只匹配一次，可以用 Times/AnyTimes 修改；
+// 没有匹配的预设时通过 TestReporter 报错并返回 ErrUnexpectedCall
+type MockStore struct {
+	t            TestReporter
+	mu           sync.Mutex
+	calls        []StoreCall
+	expectations []expectation
+}
+
+var _ Store = (*MockStore)(nil)
+
+// NewMockStore 创建 MockStore，t 实现了 Cleanup 时（如 *testing.T）在测试结束时自动调用 AssertExpectations
+func NewMockStore(t TestReporter) *MockStore {
+	m := &MockStore{t: t}
+	if c, ok := t.(interface{ Cleanup(func()) }); ok {
+		c.Cleanup(func() { m.AssertExpectations() })
+	}
+	return m
+}
+
+// expectation MockCall 与类型无关的部分
+type expectation interface {
+	matches(args []interface{}) bool
+	exhausted() bool
+	unmet() bool
+	String() string
+}
+
+// MockCall 一条预设的调用，R 为返回值类型
+type MockCall[R any] struct {
+	name   string
+	args   []interface{}
+	result R
+	err    error
+	fn     func(args ...interface{}) (R, error)
+	times  int
+	called int
+}
+
+// Return 设置返回值
+func (c *MockCall[R]) Return(result R, err error) *MockCall[R] {
+	c.result, c.err = result, err
+	return c
+}
+
+// RunAndReturn 用函数生成返回值，args 与 StoreCall.Args 相同
+func (c *MockCall[R]) RunAndReturn(fn func(args ...interface{}) (R, error)) *MockCall[R] {
+	c.fn = fn
+	return c
+}
+
+// Times 设置预期的调用次数
+func (c *MockCall[R]) Times(n int) *MockCall[R] {
+	c.times = n
+	return c
+}
+
+// AnyTimes 允许调用任意次，包括 0 次
+func (c *MockCall[R]) AnyTimes() *MockCall[R] {
+	c.times = -1
+	return c
+}
+
+func (c *MockCall[R]) matches(args []interface{}) bool {
+	if len(args) != len(c.args) {
+		return false
+	}
+	for i, want := range c.args {
+		if !argMatches(want, args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *MockCall[R]) exhausted() bool { return c.times >= 0 && c.called >= c.times }
+
+func (c *MockCall[R]) unmet() bool { return c.times >= 0 && c.called < c.times }
+
+func (c *MockCall[R]) String() string {
+	return fmt.Sprintf("%s%s (called %d/%d)", c.name, formatArgs(c.args), c.called, c.times)
+}
+
+func argMatches(want, got interface{}) bool {
+	switch w := want.(type) {
+	case anyArg:
+		return true
+	case ArgMatcher:
+		return w(got)
+	}
+	return reflect.DeepEqual(want, got)
+}
+
+func formatArgs(args []interface{}) string {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		if _, ok := a.(anyArg); ok {
+			parts[i] = "<any>"
+			continue
+		}
+		parts[i] = fmt.Sprintf("%#v", a)
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+func onCall[R any](m *MockStore, name string, args ...interface{}) *MockCall[R] {
+	c := &MockCall[R]{name: name, args: args, times: 1}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expectations = append(m.expectations, c)
+	return c
+}
+
+// dispatch 记录调用并返回第一条匹配且未用完的预设的结果
+func dispatch[R any](m *MockStore, name, collectionName string, opts []interface{}, args ...interface{}) (R, error) {
+	m.mu.Lock()
+	m.calls = append(m.calls, StoreCall{Method: name, Collection: collectionName, Args: args, Options: opts})
+	var found *MockCall[R]
+	for _, e := range m.expectations {
+		c, ok := e.(*MockCall[R])
+		if ok && c.name == name && !c.exhausted() && c.matches(args) {
+			found = c
+			break
+		}
+	}
+	if found == nil {
+		m.mu.Unlock()
+		m.t.Helper()
+		m.t.Errorf("mongodb: unexpected call %s%s", name, formatArgs(args))
+		var zero R
+		return zero, ErrUnexpectedCall
+	}
+	// fn 中可能再调用 MockStore，先释放锁
+	found.called++
+	fn, result, err := found.fn, found.result, found.err
+	m.mu.Unlock()
+	if fn != nil {
+		return fn(args...)
+	}
+	return result, err
+}
+
+// Calls 返回全部调用记录
+func (m *MockStore) Calls() []StoreCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]StoreCall(nil), m.calls...)
+}
+
+// CallsTo 返回指定方法的调用记录
+func (m *MockStore) CallsTo(method string) []StoreCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []StoreCall
+	for _, c := range m.calls {
+		if c.Method == method {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// AssertExpectations 检查所有预设都达到了预期的调用次数，返回是否全部满足
+func (m *MockStore) AssertExpectations() bool {
+	m.mu.Lock()
+	var unmet []string
+	for _, e := range m.expectations {
+		if e.unmet() {
+			unmet = append(unmet, e.String())
+		}
+	}
+	m.mu.Unlock()
+	if len(unmet) > 0 {
+		m.t.Helper()
+		m.t.Errorf("mongodb: unmet MockStore expectations:\n\t%s", strings.Join(unmet, "\n\t"))
+	}
+	return len(unmet) == 0
+}
+
+// OnFindOne 预设 FindOne 调用
+func (m *MockStore) OnFindOne(collectionName string, filter interface{}) *MockCall[bson.M] {
+	return onCall[bson.M](m, "FindOne", collectionName, filter)
+}
+
+// OnFindList 预设 FindList 调用
+func (m *MockStore) OnFindList(collectionName string, filter interface{}) *MockCall[[]bson.M] {
+	return onCall[[]bson.M](m, "FindList", collectionName, filter)
+}
+
+// OnCount 预设 Count 调用
+func (m *MockStore) OnCount(collectionName string, filter interface{}) *MockCall[int64] {
+	return onCall[int64](m, "Count", collectionName, filter)
+}
+
+// OnInsertOne 预设 InsertOne 调用
+func (m *MockStore) OnInsertOne(collectionName string, document interface{}) *MockCall[*mongo.InsertOneResult] {
+	return onCall[*mongo.InsertOneResult](m, "InsertOne", collectionName, document)
+}
+
+// OnUpdate 预设 Update 调用
+func (m *MockStore) OnUpdate(collectionName, updateType string, filter interface{}, document interface{}) *MockCall[*mongo.UpdateResult] {
+	return onCall[*mongo.UpdateResult](m, "Update", collectionName, updateType, filter, document)
+}
+
+// OnDelete 预设 Delete 调用
+func (m *MockStore) OnDelete(collectionName, deleteType string, filter interface{}) *MockCall[*mongo.DeleteResult] {
+	return onCall[*mongo.DeleteResult](m, "Delete", collectionName, deleteType, filter)
+}
+
+// OnFindOneAndUpdate 预设 FindOneAndUpdate 调用
+func (m *MockStore) OnFindOneAndUpdate(collectionName string, filter interface{}, document interface{}, operators bson.D) *MockCall[bson.M] {
+	return onCall[bson.M](m, "FindOneAndUpdate", collectionName, filter, document, operators)
+}
+
+// OnUpsert 预设 Upsert 调用
+func (m *MockStore) OnUpsert(collectionName string, filter interface{}, document interface{}, defaults bson.M) *MockCall[*UpsertResult] {
+	return onCall[*UpsertResult](m, "Upsert", collectionName, filter, document, defaults)
+}
+
+// OnPatch 预设 Patch 调用
+func (m *MockStore) OnPatch(collectionName, updateType string, filter interface{}, document interface{}) *MockCall[*mongo.UpdateResult] {
+	return onCall[*mongo.UpdateResult](m, "Patch", collectionName, updateType, filter, document)
+}
+
+// OnApplyMergePatch 预设 ApplyMergePatch 调用，patch 可以是 []byte、AnyArg 或 ArgMatcher
+func (m *MockStore) OnApplyMergePatch(collectionName string, filter interface{}, patch interface{}) *MockCall[*mongo.UpdateResult] {
+	return onCall[*mongo.UpdateResult](m, "ApplyMergePatch", collectionName, filter, patch)
+}
+
+// OnApplyJSONPatch 预设 ApplyJSONPatch 调用，patch 可以是 []byte、AnyArg 或 ArgMatcher
+func (m *MockStore) OnApplyJSONPatch(collectionName string, filter interface{}, patch interface{}) *MockCall[*mongo.UpdateResult] {
+	return onCall[*mongo.UpdateResult](m, "ApplyJSONPatch", collectionName, filter, patch)
+}
+
+func (m *MockStore) FindOne(ctx context.Context, collectionName string, filter interface{}, opts ...*options.FindOneOptions) (bson.M, error) {
+	return dispatch[bson.M](m, "FindOne", collectionName, toInterfaces(opts), collectionName, filter)
+}
+
+func (m *MockStore) FindList(ctx context.Context, collectionName string, filter interface{}, opts ...*options.FindOptions) ([]bson.M, error) {
+	return dispatch[[]bson.M](m, "FindList", collectionName, toInterfaces(opts), collectionName, filter)
+}
+
+func (m *MockStore) Count(ctx context.Context, collectionName string, filter interface{}, opts ...*options.CountOptions) (int64, error) {
+	return dispatch[int64](m, "Count", collectionName, toInterfaces(opts), collectionName, filter)
+}
+
+func (m *MockStore) InsertOne(ctx context.Context, collectionName string, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	return dispatch[*mongo.InsertOneResult](m, "InsertOne", collectionName, toInterfaces(opts), collectionName, document)
+}
+
+func (m *MockStore) Update(ctx context.Context, collectionName, updateType string, filter interface{}, document interface{}, opts ...interface{}) (*mongo.UpdateResult, error) {
+	return dispatch[*mongo.UpdateResult](m, "Update", collectionName, opts, collectionName, updateType, filter, document)
+}
+
+func (m *MockStore) Delete(ctx context.Context, collectionName, deleteType string, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	return dispatch[*mongo.DeleteResult](m, "Delete", collectionName, toInterfaces(opts), collectionName, deleteType, filter)
+}
+
+func (m *MockStore) FindOneAndUpdate(ctx context.Context, collectionName string, filter interface{}, document interface{}, operators bson.D, opts ...*options.FindOneAndUpdateOptions) (bson.M, error) {
+	return dispatch[bson.M](m, "FindOneAndUpdate", collectionName, toInterfaces(opts), collectionName, filter, document, operators)
+}
+
+func (m *MockStore) Upsert(ctx context.Context, collectionName string, filter interface{}, document interface{}, defaults bson.M) (*UpsertResult, error) {
+	return dispatch[*UpsertResult](m, "Upsert", collectionName, nil, collectionName, filter, document, defaults)
+}
+
+func (m *MockStore) Patch(ctx context.Context, collectionName, updateType string, filter interface{}, document interface{}, opts ...interface{}) (*mongo.UpdateResult, error) {
+	return dispatch[*mongo.UpdateResult](m, "Patch", collectionName, opts, collectionName, updateType, filter, document)
+}
+
+func (m *MockStore) ApplyMergePatch(ctx context.Context, collectionName string, filter interface{}, patch []byte) (*mongo.UpdateResult, error) {
+	return dispatch[*mongo.UpdateResult](m, "ApplyMergePatch", collectionName, nil, collectionName, filter, patch)
+}
+
+func (m *MockStore) ApplyJSONPatch(ctx context.Context, collectionName string, filter interface{}, patch []byte) (*mongo.UpdateResult, error) {
+	return dispatch[*mongo.UpdateResult](m, "ApplyJSONPatch", collectionName, nil, collectionName, filter, patch)
+}
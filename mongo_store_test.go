@@ -0,0 +1,117 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// fakeReporter 记录 MockStore 报告的错误
+type fakeReporter struct {
+	errors []string
+}
+
+func (r *fakeReporter) Helper() {}
+
+func (r *fakeReporter) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+// userService 依赖 Store 的业务代码示例
+type userService struct {
+	store Store
+}
+
+func (s *userService) rename(ctx context.Context, id int, name string) error {
+	if _, err := s.store.FindOne(ctx, "user", bson.M{"_id": id}); err != nil {
+		return err
+	}
+	_, err := s.store.Update(ctx, "user", string(KindUpdateOne), bson.M{"_id": id}, bson.M{"name": name})
+	return err
+}
+
+func TestMockStore(t *testing.T) {
+	ctx := context.Background()
+	m := NewMockStore(t)
+	m.OnFindOne("user", bson.M{"_id": 1}).Return(bson.M{"_id": 1, "name": "Alice"}, nil)
+	m.OnUpdate("user", string(KindUpdateOne), AnyArg, ArgMatcher(func(v interface{}) bool {
+		return v.(bson.M)["name"] == "Bob"
+	})).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+
+	svc := &userService{store: m}
+	assert.NoError(t, svc.rename(ctx, 1, "Bob"))
+	calls := m.Calls()
+	assert.Len(t, calls, 2)
+	assert.Equal(t, "Update", calls[1].Method)
+	assert.Equal(t, "user", calls[1].Collection)
+	assert.Len(t, m.CallsTo("FindOne"), 1)
+
+	// 预设默认只匹配一次
+	r := &fakeReporter{}
+	m2 := NewMockStore(r)
+	m2.OnCount("user", AnyArg).Return(3, nil)
+	m2.OnInsertOne("user", AnyArg).Times(2).Return(&mongo.InsertOneResult{InsertedID: 1}, nil)
+	n, err := m2.Count(ctx, "user", bson.M{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	_, err = m2.Count(ctx, "user", bson.M{})
+	assert.ErrorIs(t, err, ErrUnexpectedCall)
+	assert.Len(t, r.errors, 1)
+
+	_, _ = m2.InsertOne(ctx, "user", bson.M{})
+	assert.False(t, m2.AssertExpectations())
+	assert.Contains(t, r.errors[1], "InsertOne")
+	assert.Contains(t, r.errors[1], "called 1/2")
+}
+
+func TestDefaultStore(t *testing.T) {
+	useTestMemoryBackend(t)
+	ctx := context.Background()
+	var s Store = DefaultStore()
+
+	res, err := s.InsertOne(ctx, "user", bson.M{"_id": 1, "name": "Alice"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), res.InsertedID)
+
+	svc := &userService{store: s}
+	assert.NoError(t, svc.rename(ctx, 1, "Bob"))
+	doc, err := s.FindOne(ctx, "user", bson.M{"_id": 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "Bob", doc["name"])
+
+	_, err = s.Patch(ctx, "user", string(KindUpdateOne), bson.M{"_id": 1}, struct {
+		Nickname PatchField[string] `bson:"nickname"`
+	}{Nickname: SetField("b")})
+	assert.NoError(t, err)
+	_, err = s.ApplyMergePatch(ctx, "user", bson.M{"_id": 1}, []byte(`{"age": 20}`))
+	assert.NoError(t, err)
+	_, err = s.ApplyJSONPatch(ctx, "user", bson.M{"_id": 1}, []byte(`[{"op": "remove", "path": "/nickname"}]`))
+	assert.NoError(t, err)
+	doc, _ = s.FindOne(ctx, "user", bson.M{"_id": 1})
+	assert.Equal(t, int32(20), doc["age"])
+	assert.NotContains(t, doc, "nickname")
+}
+
+func TestMockStorePatch(t *testing.T) {
+	ctx := context.Background()
+	m := NewMockStore(t)
+	patch := []byte(`[{"op": "replace", "path": "/name", "value": "Bob"}]`)
+	m.OnApplyJSONPatch("user", bson.M{"_id": 1}, patch).Return(&mongo.UpdateResult{MatchedCount: 1}, nil)
+	m.OnApplyMergePatch("user", AnyArg, AnyArg).Return(&mongo.UpdateResult{}, nil)
+	m.OnPatch("user", string(KindUpdateOne), AnyArg, AnyArg).Return(&mongo.UpdateResult{ModifiedCount: 1}, nil)
+
+	var s Store = m
+	res, err := s.ApplyJSONPatch(ctx, "user", bson.M{"_id": 1}, patch)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), res.MatchedCount)
+	_, err = s.ApplyMergePatch(ctx, "user", bson.M{"_id": 2}, []byte(`{}`))
+	assert.NoError(t, err)
+	res, err = s.Patch(ctx, "user", string(KindUpdateOne), bson.M{"_id": 1}, bson.M{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), res.ModifiedCount)
+	assert.Len(t, m.CallsTo("ApplyJSONPatch"), 1)
+}
@@ -0,0 +1,206 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// DefaultTenantField 按字段隔离时默认使用的租户字段
+const DefaultTenantField = "tenant_id"
+
+var (
+	// ErrNoTenant 访问需要隔离的集合时 ctx 中没有租户
+	ErrNoTenant = errors.New("mongodb: tenant required")
+	// ErrTenantMismatch 文档中的租户与 ctx 中的租户不一致，或更新试图修改租户字段
+	ErrTenantMismatch = errors.New("mongodb: tenant mismatch")
+)
+
+// TenantMode 多租户的隔离方式
+type TenantMode int
+
+const (
+	// TenantByField 所有租户共用集合，查询和更新的条件中加入租户字段，插入时写入租户字段
+	TenantByField TenantMode = iota
+	// TenantByDatabase 每个租户使用自己的数据库，见 TenantOptions.Database
+	TenantByDatabase
+)
+
+// TenantOptions 多租户配置
+type TenantOptions struct {
+	Mode TenantMode
+	// Field 租户字段，为空时使用 DefaultTenantField，只用于 TenantByField
+	Field string
+	// Collections 需要隔离的集合，为空时隔离全部集合。集合的历史集合（见 HistoryCollection）随之隔离
+	Collections []string
+	// Exclude 各租户共享的集合，优先于 Collections。
+	// 需要全局共享的序列号、迁移记录等集合（如 DefaultCountersCollection）应放在这里
+	Exclude []string
+	// Database 返回租户的数据库名，只用于 TenantByDatabase，为空时为 <DatabaseName()>_<tenant>
+	Database func(tenant string) string
+}
+
+type tenantKey struct{}
+
+type noTenantKey struct{}
+
+// WithTenant 在 ctx 中设置当前租户
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext 返回 WithTenant 设置的租户
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// WithoutTenant 明确跨租户访问，如后台任务和运维脚本：不加入租户条件，也不因缺少租户报错。
+// TenantByDatabase 时访问的是默认数据库
+func WithoutTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noTenantKey{}, true)
+}
+
+var (
+	tenantMu      sync.RWMutex
+	tenantEnabled bool
+	tenantOptions TenantOptions
+)
+
+// EnableTenancy 开启多租户：经过中间件的读写操作按 ctx 中的租户隔离，
+// 需要隔离的集合在 ctx 中没有租户时返回 ErrNoTenant。
+// Watch、索引和 Schema 等直接使用数据库的功能不在此列
+func EnableTenancy(opts TenantOptions) {
+	if opts.Field == "" {
+		opts.Field = DefaultTenantField
+	}
+	tenantMu.Lock()
+	defer tenantMu.Unlock()
+	tenantEnabled = true
+	tenantOptions = opts
+}
+
+// DisableTenancy 关闭多租户
+func DisableTenancy() {
+	tenantMu.Lock()
+	defer tenantMu.Unlock()
+	tenantEnabled = false
+}
+
+// tenantConfig 返回集合是否需要隔离及当前配置，历史集合与原集合一致
+func tenantConfig(collectionName string) (TenantOptions, bool) {
+	tenantMu.RLock()
+	defer tenantMu.RUnlock()
+	o := tenantOptions
+	if !tenantEnabled {
+		return o, false
+	}
+	if tenantListed(o.Exclude, collectionName) {
+		return o, false
+	}
+	return o, len(o.Collections) == 0 || tenantListed(o.Collections, collectionName)
+}
+
+func tenantListed(list []string, collectionName string) bool {
+	for _, c := range list {
+		if c == collectionName || HistoryCollection(c) == collectionName {
+			return true
+		}
+	}
+	return false
+}
+
+func tenantMiddleware(next Handler) Handler {
+	return func(ctx context.Context, op *Operation) (interface{}, error) {
+		o, ok := tenantConfig(op.Collection)
+		if !ok {
+			return next(ctx, op)
+		}
+		if bypass, _ := ctx.Value(noTenantKey{}).(bool); bypass {
+			return next(ctx, op)
+		}
+		tenant := TenantFromContext(ctx)
+		if tenant == "" {
+			return nil, fmt.Errorf("%w: %s on %s", ErrNoTenant, op.Kind, op.Collection)
+		}
+
+		if o.Mode == TenantByDatabase {
+			// 避免租户标识越界访问其它数据库
+			if strings.ContainsAny(tenant, "/\\. \"$\x00") {
+				return nil, fmt.Errorf("mongodb: invalid tenant %q for database mode", tenant)
+			}
+			name := DatabaseName() + "_" + tenant
+			if o.Database != nil {
+				name = o.Database(tenant)
+			}
+			return next(WithDatabase(ctx, name), op)
+		}
+
+		if err := scopeOperation(op, o.Field, tenant); err != nil {
+			return nil, err
+		}
+		return next(ctx, op)
+	}
+}
+
+// scopeOperation 插入时写入租户字段，其它操作在条件中加入租户字段
+func scopeOperation(op *Operation, field, tenant string) error {
+	if op.Kind == KindInsertOne {
+		doc, err := stampTenant(op.Document, field, tenant)
+		if err != nil {
+			return err
+		}
+		op.Document = doc
+		return nil
+	}
+
+	op.Filter = andFilter(op.Filter, bson.M{field: tenant})
+	switch op.Kind {
+	case KindReplaceOne:
+		doc, err := stampTenant(op.Document, field, tenant)
+		if err != nil {
+			return err
+		}
+		op.Document = doc
+	case KindUpdateOne, KindUpdateMany, KindSoftDelete, KindFindOneAndUpdate:
+		if op.Document != nil {
+			doc, err := toBsonD(op.Document)
+			if err != nil {
+				return err
+			}
+			if v, ok := lookupD(doc, field); ok && v != tenant {
+				return fmt.Errorf("%w: cannot set %s to %v", ErrTenantMismatch, field, v)
+			}
+		}
+		for _, e := range op.Operators {
+			fields, err := toBsonD(e.Value)
+			if err != nil {
+				continue
+			}
+			for _, f := range fields {
+				// $rename 的值为目标字段
+				target, _ := f.Value.(string)
+				if f.Key == field || strings.HasPrefix(f.Key, field+".") || (e.Key == "$rename" && target == field) {
+					return fmt.Errorf("%w: cannot %s %s", ErrTenantMismatch, e.Key, field)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// stampTenant 写入租户字段，文档中已有其它租户时报错
+func stampTenant(document interface{}, field, tenant string) (bson.D, error) {
+	doc, err := toBsonD(document)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := lookupD(doc, field); ok && v != tenant {
+		return nil, fmt.Errorf("%w: document has %s %v, context has %q", ErrTenantMismatch, field, v, tenant)
+	}
+	return setD(doc, field, tenant), nil
+}
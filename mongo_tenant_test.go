@@ -0,0 +1,112 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestTenantByField(t *testing.T) {
+	m := useTestMemoryBackend(t)
+	EnableTenancy(TenantOptions{Exclude: []string{"dict"}})
+	defer DisableTenancy()
+
+	acme := WithTenant(context.Background(), "acme")
+	globex := WithTenant(context.Background(), "globex")
+
+	_, err := InsertOneWithContext(acme, "user", bson.M{"_id": 1, "name": "Alice"})
+	assert.NoError(t, err)
+	_, err = InsertOneWithContext(globex, "user", bson.M{"_id": 2, "name": "Bob"})
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", m.Documents("user")[0][DefaultTenantField])
+
+	// 读写都只作用于当前租户
+	list, err := FindListWithContext(acme, "user", bson.M{})
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	_, err = FindOneWithContext(acme, "user", bson.M{"_id": 2})
+	assert.True(t, errors.Is(err, mongo.ErrNoDocuments))
+	res, err := UpdateWithContext(acme, "user", string(KindUpdateMany), bson.M{}, bson.M{"active": true})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), res.MatchedCount)
+	del, err := DeleteWithContext(acme, "user", string(KindDeleteOne), bson.M{"_id": 2})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), del.DeletedCount)
+
+	// upsert 插入的文档带有租户
+	_, err = UpsertWithContext(globex, "user", bson.M{"_id": 3}, bson.M{"name": "Carol"}, nil)
+	assert.NoError(t, err)
+	n, err := CountWithContext(globex, "user", bson.M{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	// 缺少租户
+	_, err = FindListWithContext(context.Background(), "user", bson.M{})
+	assert.True(t, errors.Is(err, ErrNoTenant))
+	// 不能写入或改为其它租户
+	_, err = InsertOneWithContext(acme, "user", bson.M{"name": "Eve", DefaultTenantField: "globex"})
+	assert.True(t, errors.Is(err, ErrTenantMismatch))
+	_, err = UpdateWithContext(acme, "user", string(KindUpdateOne), bson.M{"_id": 1}, bson.M{DefaultTenantField: "globex"})
+	assert.True(t, errors.Is(err, ErrTenantMismatch))
+	_, err = FindOneAndUpdateWithContext(acme, "user", bson.M{"_id": 1}, nil, bson.D{{Key: "$unset", Value: bson.M{DefaultTenantField: ""}}})
+	assert.True(t, errors.Is(err, ErrTenantMismatch))
+
+	// 明确跨租户访问和共享集合
+	n, err = CountWithContext(WithoutTenant(context.Background()), "user", bson.M{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	_, err = InsertOneWithContext(context.Background(), "dict", bson.M{"_id": "cn"})
+	assert.NoError(t, err)
+	assert.NotContains(t, m.Documents("dict")[0], DefaultTenantField)
+}
+
+func TestTenantByDatabase(t *testing.T) {
+	m := useTestMemoryBackend(t)
+	EnableTenancy(TenantOptions{Mode: TenantByDatabase, Database: func(tenant string) string { return "app_" + tenant }})
+	defer DisableTenancy()
+
+	_, err := InsertOneWithContext(WithTenant(context.Background(), "acme"), "user", bson.M{"_id": 1})
+	assert.NoError(t, err)
+	_, err = InsertOneWithContext(WithTenant(context.Background(), "globex"), "user", bson.M{"_id": 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app_acme.user", "app_globex.user"}, m.Collections())
+	assert.Equal(t, []bson.M{{"_id": int32(1)}}, m.Documents("app_acme.user"))
+
+	_, err = FindOneWithContext(context.Background(), "user", bson.M{"_id": 1})
+	assert.True(t, errors.Is(err, ErrNoTenant))
+	_, err = FindOneWithContext(WithTenant(context.Background(), "../admin"), "user", bson.M{"_id": 1})
+	assert.Error(t, err)
+}
+
+func TestTenantHistory(t *testing.T) {
+	useTestMemoryBackend(t)
+	EnableTenancy(TenantOptions{Collections: []string{"art"}})
+	defer DisableTenancy()
+	EnableHistory("art")
+	defer DisableHistory("art")
+
+	acme := WithTenant(context.Background(), "acme")
+	globex := WithTenant(context.Background(), "globex")
+	_, err := InsertOneWithContext(acme, "art", bson.M{"_id": 1, "secret": "acme-secret"})
+	assert.NoError(t, err)
+	_, err = UpdateWithContext(acme, "art", string(KindUpdateOne), bson.M{"_id": 1}, bson.M{"secret": "acme-secret-2"})
+	assert.NoError(t, err)
+
+	revisions, err := ListRevisions(acme, "art", 1)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 2)
+
+	// 其它租户看不到历史
+	revisions, err = ListRevisions(globex, "art", 1)
+	assert.NoError(t, err)
+	assert.Empty(t, revisions)
+	_, err = FindAsOf(globex, "art", 1, time.Now().Add(-time.Hour))
+	assert.True(t, errors.Is(err, mongo.ErrNoDocuments))
+	_, err = ListRevisions(context.Background(), "art", 1)
+	assert.True(t, errors.Is(err, ErrNoTenant))
+}
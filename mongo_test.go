@@ -35,6 +35,11 @@ func init() {
 
 	// 连接到 MongoDB
 	mongoUri := os.Getenv("MongoURI")
+	if mongoUri == "" {
+		// 没有配置数据库时使用内存后端
+		UseMemoryBackend()
+		return
+	}
 	dhlog.Info(mongoUri)
 	err = db.Connect(mongoUri, 10)
 	dhlog.Info("", err)
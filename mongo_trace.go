@@ -0,0 +1,182 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Span 一次操作对应的追踪片段，方法签名与 OpenTelemetry 的 trace.Span 对应
+type Span interface {
+	SetAttributes(attrs ...Field)
+	RecordError(err error)
+	End()
+}
+
+// Tracer 追踪接口，可以用很薄的适配层接入 OpenTelemetry
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...Field) (context.Context, Span)
+}
+
+var (
+	tracerMu sync.RWMutex
+	tracer   Tracer
+)
+
+// SetTracer 设置追踪实现，传 nil 关闭追踪
+func SetTracer(t Tracer) {
+	tracerMu.Lock()
+	defer tracerMu.Unlock()
+	tracer = t
+}
+
+// GetTracer 返回当前追踪实现，未设置时返回 nil
+func GetTracer() Tracer {
+	tracerMu.RLock()
+	defer tracerMu.RUnlock()
+	return tracer
+}
+
+// startSpan 开始一次操作的追踪，返回带有 span 的 context
+func (r *opRecord) startSpan(ctx context.Context) context.Context {
+	t := GetTracer()
+	if t == nil {
+		return ctx
+	}
+	ctx, r.span = t.Start(ctx, r.Op+" "+r.Collection,
+		Field{Key: "db.system", Value: "mongodb"},
+		Field{Key: "db.name", Value: r.databaseName()},
+		Field{Key: "db.mongodb.collection", Value: r.Collection},
+		Field{Key: "db.operation", Value: r.Op},
+		Field{Key: "db.statement", Value: r.statement()},
+	)
+	return ctx
+}
+
+func (r *opRecord) endSpan() {
+	if r.span == nil {
+		return
+	}
+	switch r.Op {
+	case "FindOne", "FindList", "Count", "DeleteOne", "DeleteMany", "FindOneAndUpdate":
+		r.span.SetAttributes(Field{Key: "db.mongodb.n", Value: r.N})
+	case "InsertOne":
+	default:
+		r.span.SetAttributes(
+			Field{Key: "db.mongodb.matched", Value: r.Matched},
+			Field{Key: "db.mongodb.modified", Value: r.Modified},
+		)
+	}
+	if r.Err != nil && !errors.Is(r.Err, mongo.ErrNoDocuments) {
+		r.span.RecordError(r.Err)
+	}
+	r.span.End()
+}
+
+// statement 返回脱敏后的语句，过滤条件和文档中的值都替换为类型占位符
+func (r *opRecord) statement() string {
+	var shape interface{}
+	if r.Op == "InsertOne" {
+		shape = FilterShape(r.Document)
+	} else {
+		shape = FilterShape(r.Filter)
+	}
+	if shape == nil {
+		return "{}"
+	}
+	if b, err := bson.MarshalExtJSON(shape, false, false); err == nil {
+		return string(b)
+	}
+	return fmt.Sprint(shape)
+}
+
+// RecordedSpan RecordingTracer 记录下来的 span
+type RecordedSpan struct {
+	Name       string
+	Parent     *RecordedSpan
+	Attributes map[string]interface{}
+	Errors     []error
+	Start      time.Time
+	EndTime    time.Time
+	Ended      bool
+}
+
+// RecordingTracer 在内存中记录所有 span，用于测试
+type RecordingTracer struct {
+	mu    sync.Mutex
+	spans []*RecordedSpan
+}
+
+// NewRecordingTracer 创建 RecordingTracer
+func NewRecordingTracer() *RecordingTracer {
+	return &RecordingTracer{}
+}
+
+type recordingSpanKey struct{}
+
+func (t *RecordingTracer) Start(ctx context.Context, name string, attrs ...Field) (context.Context, Span) {
+	s := &RecordedSpan{
+		Name:       name,
+		Attributes: map[string]interface{}{},
+		Start:      time.Now(),
+	}
+	if parent, ok := ctx.Value(recordingSpanKey{}).(*RecordedSpan); ok {
+		s.Parent = parent
+	}
+	for _, a := range attrs {
+		s.Attributes[a.Key] = a.Value
+	}
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	t.mu.Unlock()
+	return context.WithValue(ctx, recordingSpanKey{}, s), &recordingSpan{t: t, s: s}
+}
+
+// Spans 返回已记录的 span 副本
+func (t *RecordingTracer) Spans() []RecordedSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]RecordedSpan, len(t.spans))
+	for i, s := range t.spans {
+		out[i] = *s
+	}
+	return out
+}
+
+// Reset 清空已记录的 span
+func (t *RecordingTracer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = nil
+}
+
+type recordingSpan struct {
+	t *RecordingTracer
+	s *RecordedSpan
+}
+
+func (rs *recordingSpan) SetAttributes(attrs ...Field) {
+	rs.t.mu.Lock()
+	defer rs.t.mu.Unlock()
+	for _, a := range attrs {
+		rs.s.Attributes[a.Key] = a.Value
+	}
+}
+
+func (rs *recordingSpan) RecordError(err error) {
+	rs.t.mu.Lock()
+	defer rs.t.mu.Unlock()
+	rs.s.Errors = append(rs.s.Errors, err)
+}
+
+func (rs *recordingSpan) End() {
+	rs.t.mu.Lock()
+	defer rs.t.mu.Unlock()
+	rs.s.EndTime = time.Now()
+	rs.s.Ended = true
+}
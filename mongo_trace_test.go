@@ -0,0 +1,40 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRecordingTracer(t *testing.T) {
+	tr := NewRecordingTracer()
+	SetTracer(tr)
+	defer SetTracer(nil)
+
+	parentCtx, parent := tr.Start(context.Background(), "GET /users")
+	rec, ctx := startOp(parentCtx, "user", "FindOne", bson.M{"email": "a@b.c"}, nil)
+	assert.NotEqual(t, parentCtx, ctx)
+	rec.finish(errors.New("boom"))
+	parent.End()
+
+	spans := tr.Spans()
+	if assert.Len(t, spans, 2) {
+		s := spans[1]
+		assert.Equal(t, "FindOne user", s.Name)
+		assert.Equal(t, "GET /users", s.Parent.Name)
+		assert.Equal(t, "mongodb", s.Attributes["db.system"])
+		assert.Equal(t, "user", s.Attributes["db.mongodb.collection"])
+		assert.Equal(t, "FindOne", s.Attributes["db.operation"])
+		assert.Equal(t, `{"email":"<string>"}`, s.Attributes["db.statement"])
+		assert.Len(t, s.Errors, 1)
+		assert.True(t, s.Ended)
+	}
+	// 按数据库隔离时记录实际使用的数据库
+	rec, _ = startOp(WithDatabase(context.Background(), "app_acme"), "user", "FindOne", nil, nil)
+	rec.finish(nil)
+	spans = tr.Spans()
+	assert.Equal(t, "app_acme", spans[len(spans)-1].Attributes["db.name"])
+}
@@ -16,7 +16,6 @@ import (
 	"fmt"
 	"time"
 
-	dhlog "github.com/lepingbeta/go-common-v2-dh-log"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -24,66 +23,112 @@ import (
 
 // updateOne 更新数据 [start]
 func UpdateWithUpdateTime(collectionName, updateType string, filter interface{}, document interface{}, opts ...interface{}) (*mongo.UpdateResult, error) {
+	return UpdateWithUpdateTimeContext(context.Background(), collectionName, updateType, filter, document, opts...)
+}
+
+// UpdateWithUpdateTimeContext 同 UpdateWithUpdateTime，钩子在追加 update_time 之前调用
+func UpdateWithUpdateTimeContext(ctx context.Context, collectionName, updateType string, filter interface{}, document interface{}, opts ...interface{}) (*mongo.UpdateResult, error) {
+	document, err := runBeforeUpdate(ctx, document)
+	if err != nil {
+		return nil, err
+	}
 	bsonD, err := Struct2BsonD(document)
 	if err != nil {
-		dhlog.Error(err.Error())
+		logf(LevelError, "转换文档失败："+err.Error(), Field{Key: "collection", Value: collectionName})
 		return nil, err
 	}
-	document = append(bsonD, bson.E{Key: "update_time", Value: time.Now().Format("2006-01-02 15:04:05")})
-	return Update(collectionName, updateType, filter, document, opts...)
+	document = append(bsonD, bson.E{Key: "update_time", Value: time.Now().Format(TimeLayout)})
+	return UpdateWithContext(ctx, collectionName, updateType, filter, document, opts...)
 }
 
 func UpdateOneBsonD(collectionName, updateType string, filter interface{}, document bson.D, opts ...interface{}) (*mongo.UpdateResult, error) {
-	document = append(document, bson.E{Key: "update_time", Value: time.Now().Format("2006-01-02 15:04:05")})
+	document = append(document, bson.E{Key: "update_time", Value: time.Now().Format(TimeLayout)})
 	return Update(collectionName, updateType, filter, document, opts...)
 }
 
 func Update(collectionName, updateType string, filter interface{}, document interface{}, opts ...interface{}) (*mongo.UpdateResult, error) {
-	collection := GetDatabase().Collection(collectionName)
-	ctx, cancel := context.WithTimeout(context.Background(), timeoutSec)
-	defer cancel()
-	update := bson.D{
-		{"$set", document},
+	return UpdateWithContext(context.Background(), collectionName, updateType, filter, document, opts...)
+}
+
+// UpdateWithContext 同 Update，ctx 用于取消和传递追踪信息。
+// document 实现 BeforeUpdater/Validator 时会在更新前调用
+func UpdateWithContext(ctx context.Context, collectionName, updateType string, filter interface{}, document interface{}, opts ...interface{}) (*mongo.UpdateResult, error) {
+	document, err := runBeforeUpdate(ctx, document)
+	if err != nil {
+		return nil, err
+	}
+	op := &Operation{Kind: OperationKind(updateType), Collection: collectionName, Filter: filter, Document: document, Options: opts}
+	switch op.Kind {
+	case KindUpdateOne, KindSoftDelete, KindUpdateMany, KindReplaceOne:
+	default:
+		return nil, fmt.Errorf("updateType 参数错误")
 	}
+	result, err := execute(ctx, op)
+	return resultAs[*mongo.UpdateResult](op, result, err)
+}
 
-	switch updateType {
-	case "UpdateOne", "softDelete":
-		var updateOpts []*options.UpdateOptions
-		for _, opt := range opts {
-			if uo, ok := opt.(*options.UpdateOptions); ok {
-				updateOpts = append(updateOpts, uo)
-			} else {
-				return nil, fmt.Errorf("invalid option type for UpdateOne")
-			}
+// updateDocument 组合更新文档：document 放在 $set 中，其后是 operators
+func updateDocument(document interface{}, operators bson.D) bson.D {
+	update := bson.D{}
+	if document != nil {
+		update = append(update, bson.E{Key: "$set", Value: document})
+	}
+	return append(update, operators...)
+}
+
+func update(ctx context.Context, collection *mongo.Collection, op *Operation) (*mongo.UpdateResult, error) {
+	update := updateDocument(op.Document, op.Operators)
+
+	switch op.Kind {
+	case KindUpdateOne, KindSoftDelete:
+		updateOpts, err := convertOpts[*options.UpdateOptions](KindUpdateOne, op.Options)
+		if err != nil {
+			return nil, err
 		}
-		dhlog.Info("UpdateOne UpdateOne")
-		return collection.UpdateOne(ctx, filter, update, updateOpts...)
-	case "UpdateMany":
-		var updateOpts []*options.UpdateOptions
-		for _, opt := range opts {
-			if uo, ok := opt.(*options.UpdateOptions); ok {
-				updateOpts = append(updateOpts, uo)
-			} else {
-				return nil, fmt.Errorf("invalid option type for UpdateOne")
-			}
+		return collection.UpdateOne(ctx, op.Filter, update, updateOpts...)
+	case KindUpdateMany:
+		updateOpts, err := convertOpts[*options.UpdateOptions](KindUpdateOne, op.Options)
+		if err != nil {
+			return nil, err
 		}
-		dhlog.Info("UpdateMany UpdateMany")
-		return collection.UpdateMany(ctx, filter, update, updateOpts...)
-	case "ReplaceOne":
-		var replaceOpts []*options.ReplaceOptions
-		for _, opt := range opts {
-			if ro, ok := opt.(*options.ReplaceOptions); ok {
-				replaceOpts = append(replaceOpts, ro)
-			} else {
-				return nil, fmt.Errorf("invalid option type for ReplaceOne")
-			}
+		return collection.UpdateMany(ctx, op.Filter, update, updateOpts...)
+	case KindReplaceOne:
+		replaceOpts, err := convertOpts[*options.ReplaceOptions](KindReplaceOne, op.Options)
+		if err != nil {
+			return nil, err
 		}
-		dhlog.Info("ReplaceOne ReplaceOne")
-		return collection.ReplaceOne(ctx, filter, document, replaceOpts...)
+		return collection.ReplaceOne(ctx, op.Filter, op.Document, replaceOpts...)
 	default:
-		dhlog.Error("updateType 参数错误")
 		return nil, fmt.Errorf("updateType 参数错误")
 	}
 }
 
+// FindOneAndUpdate 原子地更新一条数据并返回文档，document 放在 $set 中，operators 为其它更新操作符，
+// 默认返回更新前的文档，可以用 options.FindOneAndUpdate().SetReturnDocument(options.After) 修改
+func FindOneAndUpdate(collectionName string, filter interface{}, document interface{}, operators bson.D, opts ...*options.FindOneAndUpdateOptions) (bson.M, error) {
+	return FindOneAndUpdateWithContext(context.Background(), collectionName, filter, document, operators, opts...)
+}
+
+// FindOneAndUpdateWithContext 同 FindOneAndUpdate，ctx 用于取消和传递追踪信息
+func FindOneAndUpdateWithContext(ctx context.Context, collectionName string, filter interface{}, document interface{}, operators bson.D, opts ...*options.FindOneAndUpdateOptions) (bson.M, error) {
+	op := &Operation{Kind: KindFindOneAndUpdate, Collection: collectionName, Filter: filter, Document: document, Operators: operators, Options: toInterfaces(opts)}
+	result, err := execute(ctx, op)
+	return resultAs[bson.M](op, result, err)
+}
+
+func findOneAndUpdate(ctx context.Context, collection *mongo.Collection, op *Operation) (bson.M, error) {
+	opts, err := convertOpts[*options.FindOneAndUpdateOptions](op.Kind, op.Options)
+	if err != nil {
+		return nil, err
+	}
+	update := updateDocument(op.Document, op.Operators)
+
+	var result bson.M
+	err = collection.FindOneAndUpdate(ctx, op.Filter, update, opts...).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 // updateOne 更新数据 [end]
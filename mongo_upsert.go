@@ -0,0 +1,103 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UpsertResult 单条 upsert 的结果
+type UpsertResult struct {
+	// Inserted 为 true 表示新插入，否则为更新已有文档
+	Inserted   bool
+	UpsertedID interface{}
+	Matched    int64
+	Modified   int64
+}
+
+// UpsertModel UpsertMany 中的一条
+type UpsertModel struct {
+	Filter   interface{}
+	Document interface{}
+	// Defaults 只在插入时写入的默认值
+	Defaults bson.M
+}
+
+// Upsert 更新或插入一条数据 [start]
+// document 和 update_time 放在 $set 中，create_time 和 defaults 放在 $setOnInsert 中，
+// document 中的 create_time 和 defaults 中与 document 重名的字段会被忽略
+func Upsert(collectionName string, filter interface{}, document interface{}, defaults bson.M) (*UpsertResult, error) {
+	return UpsertWithContext(context.Background(), collectionName, filter, document, defaults)
+}
+
+// UpsertWithContext 同 Upsert，ctx 用于取消和传递追踪信息
+func UpsertWithContext(ctx context.Context, collectionName string, filter interface{}, document interface{}, defaults bson.M) (*UpsertResult, error) {
+	document, err := runBeforeUpdate(ctx, document)
+	if err != nil {
+		return nil, err
+	}
+	set, err := toBsonD(document)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Format(TimeLayout)
+	// create_time 只在插入时写入，同一字段同时出现在 $set 中会被 MongoDB 拒绝
+	set = setD(removeD(set, "create_time"), "update_time", now)
+
+	onInsert := bson.D{{Key: "create_time", Value: now}}
+	if len(defaults) > 0 {
+		d, err := MapToBsonD(defaults)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range d {
+			if _, ok := lookupD(set, e.Key); ok || e.Key == "create_time" {
+				continue
+			}
+			onInsert = append(onInsert, e)
+		}
+	}
+
+	op := &Operation{
+		Kind:       KindUpdateOne,
+		Collection: collectionName,
+		Filter:     filter,
+		Document:   set,
+		Operators:  bson.D{{Key: "$setOnInsert", Value: onInsert}},
+		Options:    []interface{}{options.Update().SetUpsert(true)},
+	}
+	result, err := execute(ctx, op)
+	res, err := resultAs[*mongo.UpdateResult](op, result, err)
+	if err != nil {
+		return nil, err
+	}
+	return &UpsertResult{
+		Inserted:   res.UpsertedCount > 0,
+		UpsertedID: res.UpsertedID,
+		Matched:    res.MatchedCount,
+		Modified:   res.ModifiedCount,
+	}, nil
+}
+
+// UpsertMany 逐条执行 Upsert，返回与 models 一一对应的结果，遇到错误时返回已完成的部分
+func UpsertMany(collectionName string, models []UpsertModel) ([]UpsertResult, error) {
+	return UpsertManyWithContext(context.Background(), collectionName, models)
+}
+
+// UpsertManyWithContext 同 UpsertMany，ctx 用于取消和传递追踪信息
+func UpsertManyWithContext(ctx context.Context, collectionName string, models []UpsertModel) ([]UpsertResult, error) {
+	results := make([]UpsertResult, 0, len(models))
+	for _, m := range models {
+		res, err := UpsertWithContext(ctx, collectionName, m.Filter, m.Document, m.Defaults)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, *res)
+	}
+	return results, nil
+}
+
+// Upsert 更新或插入一条数据 [end]
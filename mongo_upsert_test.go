@@ -0,0 +1,65 @@
+package mongodb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestUpsert(t *testing.T) {
+	defer ResetMiddlewares()
+	var ops []Operation
+	Use(func(next Handler) Handler {
+		return func(ctx context.Context, op *Operation) (interface{}, error) {
+			ops = append(ops, *op)
+			if len(ops) == 1 {
+				return &mongo.UpdateResult{UpsertedCount: 1, UpsertedID: "new-id"}, nil
+			}
+			return &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil
+		}
+	})
+
+	res, err := Upsert("user", bson.M{"email": "a@b.c"}, bson.M{"name": "Alice"}, bson.M{"status": "active", "name": "ignored"})
+	assert.NoError(t, err)
+	assert.True(t, res.Inserted)
+	assert.Equal(t, "new-id", res.UpsertedID)
+
+	op := ops[0]
+	set := op.Document.(bson.D)
+	assert.Equal(t, "name", set[0].Key)
+	assert.Equal(t, "update_time", set[1].Key)
+	onInsert := op.Operators[0].Value.(bson.D)
+	assert.Equal(t, "$setOnInsert", op.Operators[0].Key)
+	assert.Equal(t, "create_time", onInsert[0].Key)
+	assert.Equal(t, bson.E{Key: "status", Value: "active"}, onInsert[1])
+	assert.Len(t, onInsert, 2)
+	assert.True(t, *op.Options[0].(*options.UpdateOptions).Upsert)
+
+	results, err := UpsertMany("user", []UpsertModel{
+		{Filter: bson.M{"email": "a@b.c"}, Document: bson.M{"name": "Alice"}},
+		{Filter: bson.M{"email": "b@b.c"}, Document: bson.M{"name": "Bob"}},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.False(t, results[0].Inserted)
+	assert.Equal(t, int64(1), results[1].Modified)
+}
+
+func TestUpsertCreateTime(t *testing.T) {
+	m := useTestMemoryBackend(t)
+	// document 中的 create_time 不进入 $set，更新时保留原值
+	_, err := Upsert("user", bson.M{"_id": 1}, bson.M{"name": "Alice", "create_time": "2000-01-01 00:00:00"}, nil)
+	assert.NoError(t, err)
+	created := m.Documents("user")[0]["create_time"]
+	assert.NotEqual(t, "2000-01-01 00:00:00", created)
+
+	res, err := Upsert("user", bson.M{"_id": 1}, bson.M{"name": "Bob", "create_time": "2000-01-01 00:00:00"}, nil)
+	assert.NoError(t, err)
+	assert.False(t, res.Inserted)
+	assert.Equal(t, created, m.Documents("user")[0]["create_time"])
+	assert.Equal(t, "Bob", m.Documents("user")[0]["name"])
+}
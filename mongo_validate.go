@@ -0,0 +1,276 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	// Field 字段路径，使用 bson 字段名，如 profile.email、tags[0]
+	Field string
+	// Tag 未通过的规则，如 required、min
+	Tag   string
+	Param string
+	Value interface{}
+}
+
+func (e FieldError) Error() string {
+	switch e.Tag {
+	case "required":
+		return fmt.Sprintf("%s 不能为空", e.Field)
+	case "min":
+		return fmt.Sprintf("%s 不能小于 %s", e.Field, e.Param)
+	case "max":
+		return fmt.Sprintf("%s 不能大于 %s", e.Field, e.Param)
+	case "len":
+		return fmt.Sprintf("%s 长度必须为 %s", e.Field, e.Param)
+	case "email":
+		return fmt.Sprintf("%s 不是合法的邮箱", e.Field)
+	case "oneof":
+		return fmt.Sprintf("%s 必须是 [%s] 之一", e.Field, e.Param)
+	default:
+		return fmt.Sprintf("%s 未通过校验 %s=%s", e.Field, e.Tag, e.Param)
+	}
+}
+
+// ValidationErrors 汇总所有字段的校验错误
+type ValidationErrors []FieldError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type skipValidationKey struct{}
+
+// SkipValidation 返回跳过校验的 context，本次调用不执行 validate 标签和 Validate 方法
+func SkipValidation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipValidationKey{}, true)
+}
+
+func validationSkipped(ctx context.Context) bool {
+	skip, _ := ctx.Value(skipValidationKey{}).(bool)
+	return skip
+}
+
+var emailRegexp = regexp.MustCompile(`^[^\s@]+@[^\s@]+\.[^\s@]+$`)
+
+// ValidateStruct 按 validate 标签校验结构体，支持 required、min、max、len、email、oneof，
+// 嵌套结构体、指针和切片中的结构体会递归校验，PatchField 只校验修改了的字段。doc 不是结构体时直接返回 nil
+func ValidateStruct(doc interface{}) error {
+	v := reflect.ValueOf(doc)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs ValidationErrors
+	validateStruct("", v, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateStruct(prefix string, v reflect.Value, errs *ValidationErrors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, inline, skip := bsonFieldName(sf)
+		if skip {
+			continue
+		}
+		fv := v.Field(i)
+		path := joinPath(prefix, name)
+		if inline {
+			path = prefix
+		}
+
+		if tag, ok := sf.Tag.Lookup("validate"); ok && tag != "-" {
+			validateField(path, fv, tag, errs)
+		}
+		validateNested(path, fv, errs)
+	}
+}
+
+// validateNested 递归校验嵌套的结构体
+func validateNested(path string, fv reflect.Value, errs *ValidationErrors) {
+	fv, changed := unwrapPatchField(fv)
+	if !changed {
+		return
+	}
+	for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+		if fv.IsNil() {
+			return
+		}
+		fv = fv.Elem()
+	}
+	switch fv.Kind() {
+	case reflect.Struct:
+		if fv.NumField() > 0 && !isOpaqueStruct(fv.Type()) {
+			validateStruct(path, fv, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			validateNested(fmt.Sprintf("%s[%d]", path, i), fv.Index(i), errs)
+		}
+	}
+}
+
+// isOpaqueStruct time.Time 等没有 validate 标签的第三方结构体不递归
+func isOpaqueStruct(t reflect.Type) bool {
+	return t.PkgPath() == "time" || strings.HasPrefix(t.PkgPath(), "go.mongodb.org/")
+}
+
+func validateField(path string, fv reflect.Value, tag string, errs *ValidationErrors) {
+	fv, changed := unwrapPatchField(fv)
+	if !changed {
+		return
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		name, param, _ := strings.Cut(rule, "=")
+		if name != "required" && isEmptyValue(fv) {
+			// 非必填字段为空时跳过其余规则
+			continue
+		}
+		if !checkRule(name, param, fv) {
+			*errs = append(*errs, FieldError{Field: path, Tag: name, Param: param, Value: safeInterface(fv)})
+		}
+	}
+}
+
+func checkRule(name, param string, fv reflect.Value) bool {
+	for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+		if fv.IsNil() {
+			return name != "required"
+		}
+		fv = fv.Elem()
+	}
+	switch name {
+	case "required":
+		return !isEmptyValue(fv)
+	case "min", "max", "len":
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return false
+		}
+		size, ok := sizeOf(fv)
+		if !ok {
+			return false
+		}
+		switch name {
+		case "min":
+			return size >= n
+		case "max":
+			return size <= n
+		default:
+			return size == n
+		}
+	case "email":
+		return fv.Kind() == reflect.String && emailRegexp.MatchString(fv.String())
+	case "oneof":
+		s := fmt.Sprint(fv.Interface())
+		for _, opt := range strings.Fields(param) {
+			if s == opt {
+				return true
+			}
+		}
+		return false
+	default:
+		// 未知规则视为配置错误
+		return false
+	}
+}
+
+// unwrapPatchField 取出 PatchField 中的值，其它字段原样返回。
+// 不修改（包括 nil 的 *PatchField）时 changed 为 false，不校验；设为 null 或删除字段时按空值校验
+func unwrapPatchField(fv reflect.Value) (value reflect.Value, changed bool) {
+	if !fv.IsValid() || !fv.Type().Implements(patchValuerType) || !fv.CanInterface() {
+		return fv, true
+	}
+	if fv.Kind() == reflect.Ptr && fv.IsNil() {
+		return reflect.Value{}, false
+	}
+	switch state, v := fv.Interface().(patchValuer).patchValue(); state {
+	case PatchUnchanged:
+		return reflect.Value{}, false
+	case PatchSet:
+		return reflect.ValueOf(v), true
+	default:
+		return reflect.Value{}, true
+	}
+}
+
+// sizeOf 数字返回其值，字符串返回字符数，切片和 map 返回长度
+func sizeOf(fv reflect.Value) (float64, bool) {
+	switch fv.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(fv.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(fv.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), true
+	}
+	return 0, false
+}
+
+func isEmptyValue(fv reflect.Value) bool {
+	if !fv.IsValid() {
+		return true
+	}
+	switch fv.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return fv.IsNil() || (fv.Kind() != reflect.Ptr && fv.Kind() != reflect.Interface && fv.Len() == 0)
+	}
+	return fv.IsZero()
+}
+
+func safeInterface(fv reflect.Value) interface{} {
+	if fv.IsValid() && fv.CanInterface() {
+		return fv.Interface()
+	}
+	return nil
+}
+
+// bsonFieldName 按 bson 标签返回字段名，inline 表示内联，skip 表示忽略
+func bsonFieldName(sf reflect.StructField) (name string, inline bool, skip bool) {
+	tag := sf.Tag.Get("bson")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	for _, p := range parts[1:] {
+		if p == "inline" {
+			inline = true
+		}
+	}
+	if name == "" {
+		name = strings.ToLower(sf.Name)
+	}
+	return name, inline, false
+}
@@ -0,0 +1,67 @@
+package mongodb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type validateAddress struct {
+	City string `bson:"city" validate:"required"`
+}
+
+type validateUser struct {
+	Name     string            `bson:"name" validate:"required,min=2,max=8"`
+	Email    string            `bson:"email" validate:"email"`
+	Age      int               `bson:"age" validate:"min=0,max=150"`
+	Role     string            `bson:"role" validate:"oneof=admin user"`
+	Tags     []string          `bson:"tags" validate:"max=2"`
+	Address  *validateAddress  `bson:"address"`
+	Contacts []validateAddress `bson:"contacts"`
+}
+
+func TestValidateStruct(t *testing.T) {
+	ok := validateUser{Name: "Alice", Email: "alice@example.com", Age: 20, Role: "admin"}
+	assert.NoError(t, ValidateStruct(ok))
+	assert.NoError(t, ValidateStruct(&ok))
+
+	// 非必填字段为空时不校验
+	assert.NoError(t, ValidateStruct(validateUser{Name: "Bob"}))
+
+	bad := validateUser{
+		Name:     "A",
+		Email:    "not-an-email",
+		Age:      -1,
+		Role:     "root",
+		Tags:     []string{"a", "b", "c"},
+		Address:  &validateAddress{},
+		Contacts: []validateAddress{{City: "x"}, {}},
+	}
+	err := ValidateStruct(bad)
+	errs, isValidation := err.(ValidationErrors)
+	if assert.True(t, isValidation) {
+		var fields []string
+		for _, e := range errs {
+			fields = append(fields, e.Field+":"+e.Tag)
+		}
+		assert.Equal(t, []string{
+			"name:min", "email:email", "age:min", "role:oneof", "tags:max",
+			"address.city:required", "contacts[1].city:required",
+		}, fields)
+	}
+
+	assert.NoError(t, ValidateStruct("not a struct"))
+}
+
+func TestValidateOnInsert(t *testing.T) {
+	defer ResetMiddlewares()
+	stubOperations(&mongo.InsertOneResult{})
+
+	_, err := InsertOne("user", validateUser{})
+	assert.IsType(t, ValidationErrors{}, err)
+
+	_, err = InsertOneWithContext(SkipValidation(context.Background()), "user", validateUser{})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,220 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DefaultVersionField 乐观锁默认使用的版本字段
+const DefaultVersionField = "_v"
+
+// ErrVersionConflict 更新时版本号已过期
+var ErrVersionConflict = errors.New("mongodb: version conflict")
+
+// VersionConflictError 文档存在但版本号不匹配，errors.Is(err, ErrVersionConflict) 为 true
+type VersionConflictError struct {
+	Collection string
+	Filter     interface{}
+	Expected   int64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("mongodb: version conflict on %s, expected version %d", e.Collection, e.Expected)
+}
+
+func (e *VersionConflictError) Unwrap() error {
+	return ErrVersionConflict
+}
+
+var (
+	versionMu     sync.RWMutex
+	versionFields = map[string]string{}
+)
+
+// EnableVersioning 为集合开启乐观锁：插入时版本字段初始化为 1，
+// 更新时文档中带有版本字段则匹配该版本，并原子地加 1，field 为空时使用 DefaultVersionField
+func EnableVersioning(collectionName string, field ...string) {
+	f := DefaultVersionField
+	if len(field) > 0 && field[0] != "" {
+		f = field[0]
+	}
+	versionMu.Lock()
+	defer versionMu.Unlock()
+	versionFields[collectionName] = f
+}
+
+// DisableVersioning 关闭集合的乐观锁
+func DisableVersioning(collectionName string) {
+	versionMu.Lock()
+	defer versionMu.Unlock()
+	delete(versionFields, collectionName)
+}
+
+// VersionField 返回集合的版本字段，未开启时返回空字符串
+func VersionField(collectionName string) string {
+	versionMu.RLock()
+	defer versionMu.RUnlock()
+	return versionFields[collectionName]
+}
+
+func versionMiddleware(next Handler) Handler {
+	return func(ctx context.Context, op *Operation) (interface{}, error) {
+		field := VersionField(op.Collection)
+		if field == "" {
+			return next(ctx, op)
+		}
+
+		switch op.Kind {
+		case KindInsertOne:
+			doc, err := toBsonD(op.Document)
+			if err != nil {
+				return nil, err
+			}
+			if v, ok := lookupD(doc, field); !ok || toInt64(v) == 0 {
+				doc = setD(doc, field, int64(1))
+			}
+			op.Document = doc
+			return next(ctx, op)
+
+		case KindUpdateOne, KindUpdateMany, KindSoftDelete, KindReplaceOne:
+			doc, err := toBsonD(op.Document)
+			if err != nil {
+				return nil, err
+			}
+			expected, hasVersion := lookupD(doc, field)
+			if op.Kind == KindReplaceOne && !hasVersion {
+				// 替换文档不带版本号时以当前版本为准，避免版本号回退
+				current, err := next(ctx, &Operation{Kind: KindFindOne, Collection: op.Collection, Filter: op.Filter})
+				switch {
+				case err == nil:
+					expected, hasVersion = current.(bson.M)[field], true
+				case !errors.Is(err, mongo.ErrNoDocuments):
+					return nil, err
+				}
+			}
+			origFilter := op.Filter
+			if hasVersion {
+				op.Filter = andFilter(op.Filter, bson.M{field: expected})
+			}
+			if op.Kind == KindReplaceOne {
+				doc = setD(doc, field, toInt64(expected)+1)
+				op.Document = doc
+			} else {
+				doc = removeD(doc, field)
+				if len(doc) == 0 {
+					op.Document = nil
+				} else {
+					op.Document = doc
+				}
+				op.Operators = append(op.Operators, bson.E{Key: "$inc", Value: bson.M{field: 1}})
+			}
+
+			result, err := next(ctx, op)
+			if err != nil || !hasVersion {
+				return result, err
+			}
+			if res, ok := result.(*mongo.UpdateResult); ok && res.MatchedCount == 0 && res.UpsertedCount == 0 {
+				// 区分文档不存在和版本号过期
+				n, err := next(ctx, &Operation{Kind: KindCount, Collection: op.Collection, Filter: origFilter})
+				if err != nil {
+					return result, err
+				}
+				if c, _ := n.(int64); c > 0 {
+					return result, &VersionConflictError{Collection: op.Collection, Filter: origFilter, Expected: toInt64(expected)}
+				}
+			}
+			return result, nil
+		}
+		return next(ctx, op)
+	}
+}
+
+// andFilter 将两个过滤条件用 $and 组合，a 为空时直接返回 b
+func andFilter(a interface{}, b interface{}) interface{} {
+	switch v := a.(type) {
+	case nil:
+		return b
+	case bson.M:
+		if len(v) == 0 {
+			return b
+		}
+	case bson.D:
+		if len(v) == 0 {
+			return b
+		}
+	}
+	return bson.M{"$and": bson.A{a, b}}
+}
+
+// toBsonD 将 bson.D、bson.M、map 或结构体转为新的 bson.D，不修改原值
+func toBsonD(doc interface{}) (bson.D, error) {
+	switch v := doc.(type) {
+	case nil:
+		return bson.D{}, nil
+	case bson.D:
+		return append(bson.D{}, v...), nil
+	case bson.M:
+		return MapToBsonD(v)
+	case map[string]interface{}:
+		return MapToBsonD(v)
+	default:
+		return Struct2BsonD(doc)
+	}
+}
+
+func lookupD(doc bson.D, key string) (interface{}, bool) {
+	for _, e := range doc {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// setD 设置或追加 key
+func setD(doc bson.D, key string, value interface{}) bson.D {
+	for i, e := range doc {
+		if e.Key == key {
+			doc[i].Value = value
+			return doc
+		}
+	}
+	return append(doc, bson.E{Key: key, Value: value})
+}
+
+func removeD(doc bson.D, key string) bson.D {
+	out := doc[:0]
+	for _, e := range doc {
+		if e.Key != key {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	case float32:
+		return int64(n)
+	case uint:
+		return int64(n)
+	case uint32:
+		return int64(n)
+	case uint64:
+		return int64(n)
+	}
+	return 0
+}
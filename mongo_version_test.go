@@ -0,0 +1,87 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestVersionMiddleware(t *testing.T) {
+	EnableVersioning("order")
+	defer DisableVersioning("order")
+
+	var ops []Operation
+	matched := int64(0)
+	h := versionMiddleware(func(ctx context.Context, op *Operation) (interface{}, error) {
+		ops = append(ops, *op)
+		switch op.Kind {
+		case KindCount:
+			return int64(1), nil
+		case KindInsertOne:
+			return &mongo.InsertOneResult{}, nil
+		}
+		return &mongo.UpdateResult{MatchedCount: matched}, nil
+	})
+	ctx := context.Background()
+
+	// 插入时初始化版本号
+	_, err := h(ctx, &Operation{Kind: KindInsertOne, Collection: "order", Document: bson.M{"no": "A1"}})
+	assert.NoError(t, err)
+	assert.Equal(t, bson.D{{Key: "no", Value: "A1"}, {Key: DefaultVersionField, Value: int64(1)}}, ops[0].Document)
+
+	// 版本号过期
+	_, err = h(ctx, &Operation{Kind: KindUpdateOne, Collection: "order", Filter: bson.M{"no": "A1"},
+		Document: bson.D{{Key: "status", Value: "paid"}, {Key: DefaultVersionField, Value: int32(3)}}})
+	assert.True(t, errors.Is(err, ErrVersionConflict))
+	var conflict *VersionConflictError
+	if assert.True(t, errors.As(err, &conflict)) {
+		assert.Equal(t, int64(3), conflict.Expected)
+	}
+	assert.Equal(t, bson.M{"$and": bson.A{bson.M{"no": "A1"}, bson.M{DefaultVersionField: int32(3)}}}, ops[1].Filter)
+	assert.Equal(t, bson.D{{Key: "status", Value: "paid"}}, ops[1].Document)
+	assert.Equal(t, bson.D{{Key: "$inc", Value: bson.M{DefaultVersionField: 1}}}, ops[1].Operators)
+	assert.Equal(t, KindCount, ops[2].Kind)
+	assert.Equal(t, bson.M{"no": "A1"}, ops[2].Filter)
+
+	// 匹配成功
+	matched = 1
+	_, err = h(ctx, &Operation{Kind: KindUpdateOne, Collection: "order", Filter: bson.M{"no": "A1"},
+		Document: bson.D{{Key: DefaultVersionField, Value: int64(3)}}})
+	assert.NoError(t, err)
+	assert.Nil(t, ops[3].Document)
+
+	// 未开启的集合不做处理
+	op := &Operation{Kind: KindInsertOne, Collection: "user", Document: bson.M{"name": "Alice"}}
+	_, err = h(ctx, op)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"name": "Alice"}, op.Document)
+}
+
+func TestVersionReplaceWithoutVersion(t *testing.T) {
+	m := useTestMemoryBackend(t)
+	EnableVersioning("order")
+	defer DisableVersioning("order")
+	assert.NoError(t, m.Seed("order", bson.M{"_id": 1, "status": "paid", DefaultVersionField: int64(7)}))
+
+	// 替换文档不带版本号时在当前版本上加 1，而不是回退到 1
+	res, err := UpdateWithContext(context.Background(), "order", string(KindReplaceOne), bson.M{"_id": 1}, bson.M{"status": "shipped"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), res.ModifiedCount)
+	assert.Equal(t, []bson.M{{"_id": int32(1), "status": "shipped", DefaultVersionField: int64(8)}}, m.Documents("order"))
+
+	// 持有旧版本的替换被拒绝
+	_, err = UpdateWithContext(context.Background(), "order", string(KindReplaceOne), bson.M{"_id": 1},
+		bson.M{"status": "paid", DefaultVersionField: int64(1)})
+	assert.True(t, errors.Is(err, ErrVersionConflict))
+
+	// 文档不存在时 upsert 从 1 开始
+	_, err = UpdateWithContext(context.Background(), "order", string(KindReplaceOne), bson.M{"_id": 2}, bson.M{"status": "new"},
+		options.Replace().SetUpsert(true))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), m.Documents("order")[1][DefaultVersionField])
+}
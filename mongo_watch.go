@@ -0,0 +1,264 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultTokensCollection 默认保存 resume token 的集合
+const DefaultTokensCollection = "change_stream_tokens"
+
+// ChangeEvent change stream 事件，FullDocument 解码为 T
+type ChangeEvent[T any] struct {
+	// ID 即 resume token
+	ID                bson.Raw             `bson:"_id"`
+	OperationType     string               `bson:"operationType"`
+	FullDocument      *T                   `bson:"fullDocument"`
+	DocumentKey       bson.M               `bson:"documentKey"`
+	UpdateDescription *UpdateDescription   `bson:"updateDescription"`
+	ClusterTime       primitive.Timestamp  `bson:"clusterTime"`
+	Namespace         ChangeEventNamespace `bson:"ns"`
+}
+
+// UpdateDescription update 事件中变更的字段
+type UpdateDescription struct {
+	UpdatedFields bson.M   `bson:"updatedFields"`
+	RemovedFields []string `bson:"removedFields"`
+}
+
+// ChangeEventNamespace 事件所属的库和集合
+type ChangeEventNamespace struct {
+	DB         string `bson:"db"`
+	Collection string `bson:"coll"`
+}
+
+// ResumeTokenStore 保存 resume token，key 为 WatchOptions.Name
+type ResumeTokenStore interface {
+	Load(ctx context.Context, key string) (bson.Raw, error)
+	Save(ctx context.Context, key string, token bson.Raw) error
+}
+
+// mongoTokenStore 将 token 保存在集合中
+type mongoTokenStore struct {
+	collectionName string
+}
+
+// NewMongoTokenStore 返回保存在 collectionName 集合中的 ResumeTokenStore，为空时使用 DefaultTokensCollection
+func NewMongoTokenStore(collectionName string) ResumeTokenStore {
+	if collectionName == "" {
+		collectionName = DefaultTokensCollection
+	}
+	return &mongoTokenStore{collectionName: collectionName}
+}
+
+func (s *mongoTokenStore) Load(ctx context.Context, key string) (bson.Raw, error) {
+	doc, err := FindOneWithContext(ctx, s.collectionName, bson.M{"_id": key})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if doc["token"] == nil {
+		return nil, nil
+	}
+	return bson.Marshal(doc["token"])
+}
+
+func (s *mongoTokenStore) Save(ctx context.Context, key string, token bson.Raw) error {
+	_, err := UpsertWithContext(ctx, s.collectionName, bson.M{"_id": key}, bson.M{"token": token}, nil)
+	return err
+}
+
+// MemoryTokenStore 保存在内存中的 ResumeTokenStore，进程重启后丢失，主要用于测试
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]bson.Raw
+}
+
+// NewMemoryTokenStore 创建 MemoryTokenStore
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{tokens: map[string]bson.Raw{}}
+}
+
+func (s *MemoryTokenStore) Load(ctx context.Context, key string) (bson.Raw, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens[key], nil
+}
+
+func (s *MemoryTokenStore) Save(ctx context.Context, key string, token bson.Raw) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[key] = token
+	return nil
+}
+
+// WatchOptions Watch 的配置
+type WatchOptions struct {
+	// Name 保存 token 的 key，同一集合有多个监听者时需要区分，为空时使用集合名
+	Name string
+	// Store 为 nil 时使用 NewMongoTokenStore("")
+	Store ResumeTokenStore
+	// FullDocument 为空时使用 options.UpdateLookup，update 事件也会带上完整文档
+	FullDocument options.FullDocument
+	// MinRetryDelay/MaxRetryDelay 出错后重连的退避时间，默认 1s 和 1min
+	MinRetryDelay time.Duration
+	MaxRetryDelay time.Duration
+}
+
+// ErrChangeStreamInvalidated 集合被删除或重命名，change stream 已失效
+var ErrChangeStreamInvalidated = errors.New("mongodb: change stream invalidated")
+
+// Watch 监听集合的 change stream，直到 ctx 取消（返回 nil）或 handler 返回错误。
+// 每个事件处理成功后保存 resume token，重启或出现临时错误后从 token 处继续（startAfter，需要 MongoDB 4.2+）；
+// handler 出错时不保存该事件的 token 并返回错误，下次启动会重新收到该事件。
+// 收到 invalidate 事件时交给 handler 并保存 token，然后返回 ErrChangeStreamInvalidated，
+// 再次调用 Watch 从失效之后开始监听
+func Watch[T any](ctx context.Context, collectionName string, pipeline interface{}, handler func(ctx context.Context, ev ChangeEvent[T]) error, opts ...WatchOptions) error {
+	var o WatchOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Name == "" {
+		o.Name = collectionName
+	}
+	if o.Store == nil {
+		o.Store = NewMongoTokenStore("")
+	}
+	if o.FullDocument == "" {
+		o.FullDocument = options.UpdateLookup
+	}
+	if o.MinRetryDelay <= 0 {
+		o.MinRetryDelay = time.Second
+	}
+	if o.MaxRetryDelay < o.MinRetryDelay {
+		o.MaxRetryDelay = time.Minute
+	}
+	if pipeline == nil {
+		pipeline = mongo.Pipeline{}
+	}
+
+	delay := o.MinRetryDelay
+	for {
+		processed, err := watchOnce(ctx, collectionName, pipeline, handler, o)
+		if ctx.Err() != nil {
+			return nil
+		}
+		var he *handlerError
+		if errors.As(err, &he) {
+			return he.err
+		}
+		if errors.Is(err, ErrChangeStreamInvalidated) {
+			return err
+		}
+		if isHistoryLost(err) {
+			return err
+		}
+		if processed {
+			delay = o.MinRetryDelay
+		}
+		logf(LevelWarn, "change stream 中断，准备重连",
+			Field{Key: "collection", Value: collectionName},
+			Field{Key: "delay", Value: delay},
+			Field{Key: "error", Value: errString(err)},
+		)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		delay = nextRetryDelay(delay, o.MaxRetryDelay)
+	}
+}
+
+// nextRetryDelay 退避时间翻倍，不超过 max
+func nextRetryDelay(delay, max time.Duration) time.Duration {
+	delay *= 2
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// changeStream *mongo.ChangeStream 中 Watch 用到的方法
+type changeStream interface {
+	Next(ctx context.Context) bool
+	Decode(v interface{}) error
+	ResumeToken() bson.Raw
+	Err() error
+	Close(ctx context.Context) error
+}
+
+// openChangeStream 打开 change stream，测试中可替换
+var openChangeStream = func(ctx context.Context, collectionName string, pipeline interface{}, opts *options.ChangeStreamOptions) (changeStream, error) {
+	cs, err := GetDatabaseWithContext(ctx).Collection(collectionName).Watch(ctx, pipeline, opts)
+	if err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// handlerError 区分 handler 返回的错误和 stream 的错误
+type handlerError struct {
+	err error
+}
+
+func (e *handlerError) Error() string { return e.err.Error() }
+
+// watchOnce 打开一次 change stream 并处理事件，processed 表示是否处理过事件
+func watchOnce[T any](ctx context.Context, collectionName string, pipeline interface{}, handler func(ctx context.Context, ev ChangeEvent[T]) error, o WatchOptions) (processed bool, err error) {
+	csOpts := options.ChangeStream().SetFullDocument(o.FullDocument)
+	token, err := o.Store.Load(ctx, o.Name)
+	if err != nil {
+		return false, err
+	}
+	if len(token) > 0 {
+		// 与 resumeAfter 不同，startAfter 也可以从 invalidate 事件的 token 继续
+		csOpts.SetStartAfter(token)
+	}
+
+	cs, err := openChangeStream(ctx, collectionName, pipeline, csOpts)
+	if err != nil {
+		return false, err
+	}
+	defer cs.Close(context.Background())
+
+	for cs.Next(ctx) {
+		var ev ChangeEvent[T]
+		if err := cs.Decode(&ev); err != nil {
+			return processed, &handlerError{err: err}
+		}
+		if err := handler(ctx, ev); err != nil {
+			return processed, &handlerError{err: err}
+		}
+		processed = true
+		if err := o.Store.Save(ctx, o.Name, cs.ResumeToken()); err != nil {
+			return processed, err
+		}
+		if ev.OperationType == "invalidate" {
+			return processed, ErrChangeStreamInvalidated
+		}
+	}
+	return processed, cs.Err()
+}
+
+// isHistoryLost token 对应的 oplog 已被覆盖，无法继续
+func isHistoryLost(err error) bool {
+	var se mongo.ServerError
+	return errors.As(err, &se) && (se.HasErrorCode(286) || se.HasErrorCode(280))
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
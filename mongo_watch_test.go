@@ -0,0 +1,164 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fakeStream 按顺序返回事件，结束后返回 err
+type fakeStream struct {
+	events []bson.M
+	pos    int
+	err    error
+}
+
+func (s *fakeStream) Next(ctx context.Context) bool {
+	if s.pos >= len(s.events) {
+		return false
+	}
+	s.pos++
+	return true
+}
+
+func (s *fakeStream) Decode(v interface{}) error {
+	data, err := bson.Marshal(s.events[s.pos-1])
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(data, v)
+}
+
+func (s *fakeStream) ResumeToken() bson.Raw {
+	data, _ := bson.Marshal(s.events[s.pos-1]["_id"])
+	return data
+}
+
+func (s *fakeStream) Err() error                      { return s.err }
+func (s *fakeStream) Close(ctx context.Context) error { return nil }
+
+func watchEvent(token, op string) bson.M {
+	return bson.M{"_id": bson.M{"_data": token}, "operationType": op, "documentKey": bson.M{"_id": token}}
+}
+
+// useFakeStreams 依次返回 streams，用完后返回 errStreamsDone；opened 记录每次打开时的 startAfter
+func useFakeStreams(t *testing.T, streams ...*fakeStream) *[]bson.Raw {
+	prev := openChangeStream
+	t.Cleanup(func() { openChangeStream = prev })
+	var opened []bson.Raw
+	openChangeStream = func(ctx context.Context, collectionName string, pipeline interface{}, opts *options.ChangeStreamOptions) (changeStream, error) {
+		token, _ := opts.StartAfter.(bson.Raw)
+		opened = append(opened, token)
+		if len(streams) == 0 {
+			return nil, errStreamsDone
+		}
+		s := streams[0]
+		streams = streams[1:]
+		return s, nil
+	}
+	return &opened
+}
+
+var errStreamsDone = errors.New("no more streams")
+
+func tokenData(t *testing.T, token bson.Raw) string {
+	var v struct {
+		Data string `bson:"_data"`
+	}
+	assert.NoError(t, bson.Unmarshal(token, &v))
+	return v.Data
+}
+
+func TestMemoryTokenStore(t *testing.T) {
+	s := NewMemoryTokenStore()
+	ctx := context.Background()
+	token, err := s.Load(ctx, "user")
+	assert.NoError(t, err)
+	assert.Nil(t, token)
+
+	data, _ := bson.Marshal(bson.M{"_data": "t1"})
+	raw := bson.Raw(data)
+	assert.NoError(t, s.Save(ctx, "user", raw))
+	token, err = s.Load(ctx, "user")
+	assert.NoError(t, err)
+	assert.Equal(t, raw, token)
+	token, _ = s.Load(ctx, "order")
+	assert.Nil(t, token)
+}
+
+func TestWatchResumeAndHandlerError(t *testing.T) {
+	opened := useFakeStreams(t,
+		&fakeStream{events: []bson.M{watchEvent("t1", "insert"), watchEvent("t2", "update")}, err: errors.New("network")},
+		&fakeStream{events: []bson.M{watchEvent("t3", "delete")}},
+	)
+	store := NewMemoryTokenStore()
+	handlerErr := errors.New("handler failed")
+	var seen []string
+	err := Watch[bson.M](context.Background(), "user", nil, func(ctx context.Context, ev ChangeEvent[bson.M]) error {
+		seen = append(seen, ev.OperationType)
+		if ev.OperationType == "delete" {
+			return handlerErr
+		}
+		return nil
+	}, WatchOptions{Store: store, MinRetryDelay: time.Millisecond})
+
+	// handler 出错时直接返回，不再重连，token 停在最后处理成功的事件
+	assert.ErrorIs(t, err, handlerErr)
+	assert.Equal(t, []string{"insert", "update", "delete"}, seen)
+	if assert.Len(t, *opened, 2) {
+		assert.Nil(t, (*opened)[0])
+		assert.Equal(t, "t2", tokenData(t, (*opened)[1]))
+	}
+	token, _ := store.Load(context.Background(), "user")
+	assert.Equal(t, "t2", tokenData(t, token))
+}
+
+func TestWatchInvalidate(t *testing.T) {
+	opened := useFakeStreams(t, &fakeStream{events: []bson.M{watchEvent("t1", "insert"), watchEvent("t2", "invalidate")}})
+	store := NewMemoryTokenStore()
+	var seen []string
+	err := Watch[bson.M](context.Background(), "user", nil, func(ctx context.Context, ev ChangeEvent[bson.M]) error {
+		seen = append(seen, ev.OperationType)
+		return nil
+	}, WatchOptions{Store: store, MinRetryDelay: time.Millisecond})
+
+	assert.ErrorIs(t, err, ErrChangeStreamInvalidated)
+	assert.Equal(t, []string{"insert", "invalidate"}, seen)
+	assert.Len(t, *opened, 1)
+	// 再次 Watch 时从 invalidate 之后开始
+	token, _ := store.Load(context.Background(), "user")
+	assert.Equal(t, "t2", tokenData(t, token))
+}
+
+func TestWatchRetryUntilCanceled(t *testing.T) {
+	opened := useFakeStreams(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		time.Sleep(60 * time.Millisecond)
+		cancel()
+	}()
+	err := Watch[bson.M](ctx, "user", nil, func(ctx context.Context, ev ChangeEvent[bson.M]) error { return nil },
+		WatchOptions{Store: NewMemoryTokenStore(), MinRetryDelay: time.Millisecond, MaxRetryDelay: 2 * time.Millisecond})
+	assert.NoError(t, err)
+	// 不封顶时 60ms 内只能重连 6 次左右
+	assert.GreaterOrEqual(t, len(*opened), 10)
+}
+
+func TestNextRetryDelay(t *testing.T) {
+	delay := time.Second
+	var delays []time.Duration
+	for i := 0; i < 8; i++ {
+		delay = nextRetryDelay(delay, time.Minute)
+		delays = append(delays, delay)
+	}
+	assert.Equal(t, []time.Duration{
+		2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second,
+		32 * time.Second, time.Minute, time.Minute, time.Minute,
+	}, delays)
+}
@@ -0,0 +1,141 @@
+package mongotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	mongodb "github.com/lepingbeta/go-common-v2-dh-mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AssertDocCount 断言集合中满足 filter 的文档数为 want
+func AssertDocCount(t testing.TB, collectionName string, filter interface{}, want int64) bool {
+	t.Helper()
+	if filter == nil {
+		filter = bson.M{}
+	}
+	op := &mongodb.Operation{Kind: mongodb.KindCount, Collection: collectionName, Filter: filter}
+	n, err := mongodb.GetBackend().Count(context.Background(), op)
+	if err != nil {
+		t.Errorf("mongotest: %s 计数失败: %v", collectionName, err)
+		return false
+	}
+	if n != want {
+		t.Errorf("mongotest: %s 中满足 %v 的文档数为 %d，期望 %d", collectionName, filter, n, want)
+		return false
+	}
+	return true
+}
+
+// AssertDocMatches 断言满足 filter 的第一条文档包含 expected 中的字段，
+// 嵌套文档按子集比较，数字按数值比较，expected 中没有的字段不检查
+func AssertDocMatches(t testing.TB, collectionName string, filter interface{}, expected interface{}) bool {
+	t.Helper()
+	op := &mongodb.Operation{Kind: mongodb.KindFindOne, Collection: collectionName, Filter: filter}
+	doc, err := mongodb.GetBackend().FindOne(context.Background(), op)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("mongotest: %s 中没有满足 %v 的文档", collectionName, filter)
+		return false
+	}
+	if err != nil {
+		t.Errorf("mongotest: %s 查询失败: %v", collectionName, err)
+		return false
+	}
+	want, err := normalize(expected)
+	if err != nil {
+		t.Errorf("mongotest: expected 无法编码: %v", err)
+		return false
+	}
+	got, _ := normalize(doc)
+	if diffs := subsetDiff("", want, got); len(diffs) > 0 {
+		t.Errorf("mongotest: %s 中满足 %v 的文档不匹配:\n\t%s\n实际文档: %v", collectionName, filter, strings.Join(diffs, "\n\t"), got)
+		return false
+	}
+	return true
+}
+
+func normalize(v interface{}) (bson.M, error) {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m bson.M
+	err = bson.Unmarshal(data, &m)
+	return m, err
+}
+
+// subsetDiff 返回 want 中与 got 不一致的字段说明
+func subsetDiff(prefix string, want, got bson.M) []string {
+	keys := make([]string, 0, len(want))
+	for k := range want {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var diffs []string
+	for _, k := range keys {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+		g, ok := got[k]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("%s: 缺少字段，期望 %v", path, want[k]))
+			continue
+		}
+		wm, wIsDoc := want[k].(bson.M)
+		gm, gIsDoc := g.(bson.M)
+		if wIsDoc && gIsDoc {
+			diffs = append(diffs, subsetDiff(path, wm, gm)...)
+			continue
+		}
+		if !valueEqual(want[k], g) {
+			diffs = append(diffs, fmt.Sprintf("%s: 实际 %v (%T)，期望 %v (%T)", path, g, g, want[k], want[k]))
+		}
+	}
+	return diffs
+}
+
+func valueEqual(want, got interface{}) bool {
+	if wf, ok := number(want); ok {
+		gf, ok := number(got)
+		return ok && wf == gf
+	}
+	wa, wIsArr := want.(bson.A)
+	ga, gIsArr := got.(bson.A)
+	if wIsArr && gIsArr {
+		if len(wa) != len(ga) {
+			return false
+		}
+		for i := range wa {
+			if !valueEqual(wa[i], ga[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	wm, wIsDoc := want.(bson.M)
+	gm, gIsDoc := got.(bson.M)
+	if wIsDoc && gIsDoc {
+		return len(wm) == len(gm) && len(subsetDiff("", wm, gm)) == 0
+	}
+	return reflect.DeepEqual(want, got)
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
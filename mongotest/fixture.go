@@ -0,0 +1,110 @@
+package mongotest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	mongodb "github.com/lepingbeta/go-common-v2-dh-mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadFixtures 加载数据文件，失败时终止测试。支持 .json、.ejson（Extended JSON）、.yaml 和 .yml，
+// JSON 和 YAML 中都可以使用 {"$oid": ...}、{"$date": ...} 等 Extended JSON 写法。
+// 文件内容为数组时集合名取文件名（不含扩展名），为对象时键为集合名、值为文档数组。
+// 数据直接写入当前后端，不经过中间件和钩子
+func (s *Sandbox) LoadFixtures(paths ...string) {
+	s.t.Helper()
+	for _, p := range paths {
+		if err := LoadFixtureFile(context.Background(), p); err != nil {
+			s.t.Fatalf("mongotest: 加载 %s 失败: %v", p, err)
+		}
+	}
+}
+
+// LoadFixtureFile 加载一个数据文件，格式见 Sandbox.LoadFixtures
+func LoadFixtureFile(ctx context.Context, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".json", ".ejson":
+	case ".yaml", ".yml":
+		if data, err = yamlToJSON(data); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("不支持的文件类型 %s", ext)
+	}
+
+	fixtures, err := parseFixtures(data, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	if err != nil {
+		return err
+	}
+	for _, f := range fixtures {
+		if err := insertFixtures(ctx, f.collection, f.docs); err != nil {
+			return fmt.Errorf("%s: %w", f.collection, err)
+		}
+	}
+	return nil
+}
+
+type fixture struct {
+	collection string
+	docs       bson.A
+}
+
+// parseFixtures 按 Extended JSON 解析，保留字段顺序
+func parseFixtures(data []byte, defaultCollection string) ([]fixture, error) {
+	var wrapper struct {
+		V interface{} `bson:"v"`
+	}
+	wrapped := append(append([]byte(`{"v":`), data...), '}')
+	if err := bson.UnmarshalExtJSON(wrapped, false, &wrapper); err != nil {
+		return nil, err
+	}
+	switch v := wrapper.V.(type) {
+	case bson.A:
+		return []fixture{{collection: defaultCollection, docs: v}}, nil
+	case bson.D:
+		out := make([]fixture, 0, len(v))
+		for _, e := range v {
+			docs, ok := e.Value.(bson.A)
+			if !ok {
+				return nil, fmt.Errorf("集合 %s 的值必须是数组", e.Key)
+			}
+			out = append(out, fixture{collection: e.Key, docs: docs})
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("数据文件的顶层必须是数组或对象")
+}
+
+func insertFixtures(ctx context.Context, collectionName string, docs bson.A) error {
+	b := mongodb.GetBackend()
+	for i, d := range docs {
+		if _, ok := d.(bson.D); !ok {
+			return fmt.Errorf("第 %d 条不是文档", i)
+		}
+		op := &mongodb.Operation{Kind: mongodb.KindInsertOne, Collection: collectionName, Document: d}
+		if _, err := b.InsertOne(ctx, op); err != nil {
+			return fmt.Errorf("第 %d 条: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// yamlToJSON 将 YAML 转为 JSON，之后按 Extended JSON 解析
+func yamlToJSON(data []byte) ([]byte, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,105 @@
+// Package mongotest 集成测试辅助：每个测试使用随机命名的独立数据库，
+// 从 JSON/YAML/Extended JSON 文件加载数据，提供断言函数，测试结束时删除数据库
+package mongotest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	mongodb "github.com/lepingbeta/go-common-v2-dh-mongo"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DefaultURI 未设置 MONGO_TEST_URI 时连接的地址
+const DefaultURI = "mongodb://localhost:27017"
+
+// Sandbox 一个测试独占的数据库。包级函数通过全局的数据库名访问，
+// 使用 Sandbox 的测试不能 t.Parallel
+type Sandbox struct {
+	t testing.TB
+	// Name 数据库名，内存模式下为空
+	Name string
+	// Memory 内存模式下的后端，连接 mongod 时为 nil
+	Memory *mongodb.MemoryBackend
+}
+
+// New 连接 MONGO_TEST_URI（默认 DefaultURI）上的 mongod，创建随机命名的数据库并切换包级函数使用它，
+// 测试结束时删除数据库并恢复原来的数据库和后端。连接不上时跳过测试
+func New(t testing.TB) *Sandbox {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	db := mongodb.GetInstance()
+	if db.GetClient() == nil {
+		uri := os.Getenv("MONGO_TEST_URI")
+		if uri == "" {
+			uri = DefaultURI
+		}
+		prevName := mongodb.DatabaseName()
+		if err := db.Connect(uri, 10); err != nil {
+			t.Skipf("mongotest: 连接 %s 失败: %v", uri, err)
+		}
+		mongodb.SetDatabaseName(prevName)
+	}
+	if err := db.GetClient().Ping(ctx, nil); err != nil {
+		t.Skipf("mongotest: mongod 不可用: %v", err)
+	}
+
+	s := &Sandbox{t: t, Name: databaseName(t.Name())}
+	prevName, prevBackend := mongodb.DatabaseName(), mongodb.GetBackend()
+	mongodb.UseMongoBackend()
+	mongodb.SetDatabaseName(s.Name)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := db.GetClient().Database(s.Name).Drop(ctx); err != nil {
+			t.Errorf("mongotest: 删除数据库 %s 失败: %v", s.Name, err)
+		}
+		mongodb.SetDatabaseName(prevName)
+		mongodb.SetBackend(prevBackend)
+	})
+	return s
+}
+
+// NewMemory 使用新的内存后端代替 mongod，接口与 New 相同，用于没有 mongod 的环境
+func NewMemory(t testing.TB) *Sandbox {
+	t.Helper()
+	prevBackend := mongodb.GetBackend()
+	s := &Sandbox{t: t, Memory: mongodb.UseMemoryBackend()}
+	t.Cleanup(func() { mongodb.SetBackend(prevBackend) })
+	return s
+}
+
+// DB 返回沙箱数据库，内存模式下为 nil
+func (s *Sandbox) DB() *mongo.Database {
+	if s.Memory != nil {
+		return nil
+	}
+	return mongodb.GetInstance().GetClient().Database(s.Name)
+}
+
+// databaseName 由测试名和随机后缀组成，去掉数据库名中不允许的字符，总长度不超过 63
+func databaseName(testName string) string {
+	var b strings.Builder
+	for _, r := range testName {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	name := b.String()
+	if len(name) > 40 {
+		name = name[:40]
+	}
+	suffix := make([]byte, 6)
+	_, _ = rand.Read(suffix)
+	return "test_" + name + "_" + hex.EncodeToString(suffix)
+}
@@ -0,0 +1,62 @@
+package mongotest
+
+import (
+	"testing"
+
+	mongodb "github.com/lepingbeta/go-common-v2-dh-mongo"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeT 记录断言失败而不终止测试
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, format)
+}
+
+func testFixtures(t *testing.T, s *Sandbox) {
+	s.LoadFixtures("testdata/project.json", "testdata/users.yaml")
+
+	AssertDocCount(t, "project", nil, 2)
+	AssertDocCount(t, "user", bson.M{"profile.age": bson.M{"$gte": 30}}, 1)
+	AssertDocCount(t, "team", bson.M{}, 1)
+
+	oid, _ := primitive.ObjectIDFromHex("6650a1b2c3d4e5f601234568")
+	AssertDocMatches(t, "project", bson.M{"_id": oid}, bson.M{"name": "beta", "stars": int64(5), "tags": bson.A{"go", "mongo"}})
+	AssertDocMatches(t, "user", bson.M{"name": "Alice"}, bson.M{"profile": bson.M{"city": "Beijing"}})
+
+	doc, err := mongodb.FindOne("project", bson.M{"name": "alpha"})
+	assert.NoError(t, err)
+	assert.IsType(t, primitive.DateTime(0), doc["created"])
+
+	f := &fakeT{TB: t}
+	assert.False(t, AssertDocCount(f, "user", nil, 3))
+	assert.False(t, AssertDocMatches(f, "user", bson.M{"_id": 1}, bson.M{"profile": bson.M{"city": "Shanghai"}}))
+	assert.False(t, AssertDocMatches(f, "user", bson.M{"_id": 9}, bson.M{}))
+	assert.Len(t, f.errors, 3)
+}
+
+func TestSandboxMemory(t *testing.T) {
+	s := NewMemory(t)
+	testFixtures(t, s)
+	assert.Equal(t, []string{"project", "team", "user"}, s.Memory.Collections())
+}
+
+func TestSandbox(t *testing.T) {
+	s := New(t)
+	assert.Equal(t, s.Name, mongodb.DatabaseName())
+	testFixtures(t, s)
+}
+
+func TestDatabaseName(t *testing.T) {
+	name := databaseName("TestA/sub case.with$chars")
+	assert.Regexp(t, `^test_TestA_sub_case_with_chars_[0-9a-f]{12}$`, name)
+	assert.LessOrEqual(t, len(databaseName(string(make([]byte, 100)))), 63)
+}